@@ -2,8 +2,10 @@ package client
 
 import (
 	"context"
+	"github.com/Pactus-Contrib/Indexer/metrics"
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	pactus "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -12,12 +14,19 @@ type Pactus struct {
 	Blockchain  pactus.BlockchainClient
 	Transaction pactus.TransactionClient
 	Network     pactus.NetworkClient
+
+	conn *grpc.ClientConn
 }
 
 func NewPactus(ctx context.Context, rpc string) (*Pactus, error) {
 	dialOpts := make([]grpc.DialOption, 0)
 	dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	dialOpts = append(dialOpts, grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor()))
+	dialOpts = append(dialOpts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	// metrics run inside retry, so every attempt is observed
+	dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(
+		grpc_retry.UnaryClientInterceptor(),
+		metrics.UnaryClientInterceptor(),
+	))
 
 	conn, err := grpc.DialContext(ctx, rpc, dialOpts...)
 	if err != nil {
@@ -25,8 +34,14 @@ func NewPactus(ctx context.Context, rpc string) (*Pactus, error) {
 	}
 
 	return &Pactus{
-		pactus.NewBlockchainClient(conn),
-		pactus.NewTransactionClient(conn),
-		pactus.NewNetworkClient(conn),
+		Blockchain:  pactus.NewBlockchainClient(conn),
+		Transaction: pactus.NewTransactionClient(conn),
+		Network:     pactus.NewNetworkClient(conn),
+		conn:        conn,
 	}, nil
 }
+
+// Close closes connection to the node.
+func (p *Pactus) Close() error {
+	return p.conn.Close()
+}
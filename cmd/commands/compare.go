@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/core"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	compareFrom uint32
+	compareTo   uint32
+	compareBase string
+	compareJSON bool
+)
+
+func init() {
+	compareCmd.Flags().Uint32Var(&compareFrom, "from", 1, "first block height")
+	compareCmd.Flags().Uint32Var(&compareTo, "to", 0,
+		"last block height, default is lowest last indexed height between databases")
+	compareCmd.Flags().StringVar(&compareBase, "base", "", "database others are compared with, default is first database")
+	compareCmd.Flags().BoolVar(&compareJSON, "json", false, "print divergent heights as json")
+
+	rootCmd.AddCommand(compareCmd)
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "compare blocks and transactions between databases and list divergent heights",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, cfg, err := databasePool(cmd)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+
+		base, err := findDB(cfg, compareBase)
+		if err != nil {
+			return err
+		}
+
+		to := compareTo
+		if to == 0 {
+			to, err = lowestIndexed(cmd.Context(), p, cfg.IndexerUuid)
+			if err != nil {
+				return err
+			}
+		}
+
+		if compareFrom == 0 || compareFrom > to {
+			return fmt.Errorf("invalid range %d..%d", compareFrom, to)
+		}
+
+		divergences, err := core.Compare(cmd.Context(), p, compareFrom, to, base.Name)
+		if err != nil {
+			return err
+		}
+
+		diverged := 0
+		for _, d := range divergences {
+			if len(d.Heights) != 0 {
+				diverged++
+			}
+		}
+
+		if compareJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(divergences); err != nil {
+				return err
+			}
+		} else {
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "DATABASE\tENGINE\tDIVERGENT\tHEIGHTS")
+			for _, d := range divergences {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", d.DB, d.Engine, len(d.Heights), orDash(heightRanges(d.Heights)))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "\nCompared heights %d..%d with %s\n", compareFrom, to, base.Name)
+		}
+
+		if diverged != 0 {
+			return fmt.Errorf("%d databases diverge from %s, use verify or reindex to fix them", diverged, base.Name)
+		}
+
+		return nil
+	},
+}
+
+// heightRanges formats sorted heights as ranges, like 3-5,9.
+func heightRanges(heights []uint32) string {
+	parts := make([]string, 0)
+	for i := 0; i < len(heights); {
+		j := i
+		for j+1 < len(heights) && heights[j+1] == heights[j]+1 {
+			j++
+		}
+
+		part := strconv.FormatUint(uint64(heights[i]), 10)
+		if j > i {
+			part += "-" + strconv.FormatUint(uint64(heights[j]), 10)
+		}
+		parts = append(parts, part)
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
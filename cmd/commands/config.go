@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/config"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/google/uuid"
+	pactusgrpc "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const _defaultCheckTimeout = 5 * time.Second
+
+var (
+	printRedacted bool
+	initForce     bool
+	checkConnect  bool
+)
+
+func init() {
+	configPrintCmd.Flags().BoolVar(&printRedacted, "redacted", false, "hide credentials of uri, sentry_dsn and secrets")
+	configInitCmd.Flags().BoolVarP(&initForce, "force", "f", false, "overwrite existing config")
+	configValidateCmd.Flags().BoolVar(&checkConnect, "connect", false,
+		"also connect to every database and pactus rpc, pactus is only checked with it. sqlite databases are "+
+			"only checked for their directory")
+
+	configCmd.AddCommand(configPrintCmd, configInitCmd, configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "inspect indexer config",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "print effective config after environment overrides and secrets are applied",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.New(configPath)
+		if err != nil {
+			return err
+		}
+
+		if printRedacted {
+			cfg, err = config.Redacted(cfg)
+			if err != nil {
+				return err
+			}
+		}
+
+		enc := yaml.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			return err
+		}
+
+		return enc.Close()
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "write a commented default config to config path",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(configPath); err == nil && !initForce {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", configPath)
+		}
+
+		cfg := *config.DefaultConfig
+		cfg.IndexerUuid = uuid.New().String()
+
+		b, err := config.Commented(&cfg)
+		if err != nil {
+			return err
+		}
+
+		if dir := filepath.Dir(configPath); len(dir) != 0 {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+
+		if err := os.WriteFile(configPath, b, 0o600); err != nil {
+			return err
+		}
+		cmd.Printf("config written to %s\n", configPath)
+
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:          "validate",
+	Short:        "report every config error with its field path",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.New(configPath)
+		if err != nil {
+			return err
+		}
+
+		errs := unwrapAll(cfg.Validate())
+		if checkConnect {
+			errs = append(errs, checkConnections(cmd.Context(), cfg)...)
+		}
+
+		for _, err := range errs {
+			cmd.PrintErrln(err)
+		}
+
+		if len(errs) != 0 {
+			return fmt.Errorf("%s has %d errors", configPath, len(errs))
+		}
+		cmd.Printf("%s is valid\n", configPath)
+
+		return nil
+	},
+}
+
+// checkConnections connects to every database and pactus, unreachable ones are reported as field errors.
+func checkConnections(ctx context.Context, cfg *schema.Config) []error {
+	errs := make([]error, 0)
+
+	for i, d := range cfg.DBS {
+		if err := checkDatabase(ctx, d); err != nil {
+			errs = append(errs, &schema.FieldError{
+				Path: fmt.Sprintf("dbs[%d].uri", i),
+				Msg:  fmt.Sprintf("can't connect to %s: %s", d.Name, err),
+			})
+		}
+	}
+
+	if cfg.Pactus != nil && len(cfg.Pactus.RPC) != 0 {
+		if err := pingPactus(ctx, cfg.Pactus.RPC); err != nil {
+			errs = append(errs, &schema.FieldError{
+				Path: "pactus.rpc",
+				Msg:  fmt.Sprintf("pactus is unreachable: %s", err),
+			})
+		}
+	}
+
+	return errs
+}
+
+// checkDatabase connects to d, a sqlite database is only checked for its directory since opening it creates the
+// file.
+func checkDatabase(ctx context.Context, d *schema.DB) error {
+	if d.Engine == schema.SQLITE {
+		dir := db.SQLiteDir(d.URI)
+		if len(dir) == 0 {
+			return nil
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s isn't a directory", dir)
+		}
+
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, _defaultCheckTimeout)
+	defer cancel()
+
+	database, err := db.Open(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	return database.Close()
+}
+
+func pingPactus(ctx context.Context, rpc string) error {
+	ctx, cancel := context.WithTimeout(ctx, _defaultCheckTimeout)
+	defer cancel()
+
+	pactus, err := client.NewPactus(ctx, rpc)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = pactus.Close() }()
+
+	_, err = pactus.Blockchain.GetBlockchainInfo(ctx, &pactusgrpc.GetBlockchainInfoRequest{})
+
+	return err
+}
+
+// unwrapAll flattens joined errors.
+func unwrapAll(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+
+	return []error{err}
+}
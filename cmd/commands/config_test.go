@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConnections_SQLite(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &schema.Config{DBS: []*schema.DB{
+		{Name: "ok", Type: schema.SQL, Engine: schema.SQLITE, URI: "file:" + filepath.Join(dir, "ok.db")},
+		{Name: "missing", Type: schema.SQL, Engine: schema.SQLITE,
+			URI: "file:" + filepath.Join(dir, "missing", "indexer.db")},
+	}}
+
+	errs := checkConnections(context.Background(), cfg)
+	if len(errs) != 1 {
+		t.Fatalf("expected only missing directory reported, got %v", errs)
+	}
+
+	if fe, ok := errs[0].(*schema.FieldError); !ok || fe.Path != "dbs[1].uri" {
+		t.Fatalf("unexpected error %v", errs[0])
+	}
+
+	// validating doesn't create database files
+	if _, err := os.Stat(filepath.Join(dir, "ok.db")); !os.IsNotExist(err) {
+		t.Fatalf("expected no database file, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/config"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/sink"
+	"github.com/spf13/cobra"
+)
+
+const _exportBatchSize = 1000
+
+var (
+	exportDB   string
+	exportOut  string
+	exportFrom uint32
+	exportTo   uint32
+)
+
+func init() {
+	exportParquetCmd.Flags().StringVar(&exportDB, "db", "", "source database name, default is first database")
+	exportParquetCmd.Flags().StringVarP(&exportOut, "out", "o", "./export", "output directory")
+	exportParquetCmd.Flags().Uint32Var(&exportFrom, "from", 1, "first block height")
+	exportParquetCmd.Flags().Uint32Var(&exportTo, "to", 0, "last block height, default is last indexed height")
+
+	exportCmd.AddCommand(exportParquetCmd)
+	rootCmd.AddCommand(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export indexed data to files",
+}
+
+var exportParquetCmd = &cobra.Command{
+	Use:   "parquet",
+	Short: "export blocks, transactions and payloads to parquet files partitioned by day",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.New(configPath)
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		logger, err := defaultLogging()
+		if err != nil {
+			return err
+		}
+
+		source, err := findDB(cfg, exportDB)
+		if err != nil {
+			return err
+		}
+
+		database, err := db.Open(cmd.Context(), source)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		to := exportTo
+		if to == 0 {
+			var indexer schema.Indexer
+			if err := database.FindOne(cmd.Context(), schema.IndexerTableName, "index_id", cfg.IndexerUuid,
+				&indexer); err != nil {
+				return err
+			}
+			if indexer.LastBlockHeight <= 1 {
+				return fmt.Errorf("no block is indexed in %s yet", source.Name)
+			}
+			to = uint32(indexer.LastBlockHeight) - 1
+		}
+
+		out, err := sink.NewParquet(&schema.Sink{Name: "export", Type: schema.PARQUET, Path: exportOut})
+		if err != nil {
+			return err
+		}
+
+		// continue an interrupted export from its last written part
+		from := exportFrom
+		cursor, err := out.Cursor(cmd.Context())
+		if err != nil {
+			return errors.Join(out.Close(), err)
+		}
+		if cursor >= from {
+			from = cursor + 1
+		}
+
+		logger.InfoContext(cmd.Context(), false, "Parquet export started", "db", source.Name,
+			"from", from, "to", to, "out", exportOut)
+
+		for start := from; start <= to; start += _exportBatchSize {
+			end := min(start+_exportBatchSize-1, to)
+			if err := exportRange(cmd, database, out, start, end); err != nil {
+				return errors.Join(out.Close(), err)
+			}
+			logger.InfoContext(cmd.Context(), false, "Exported", "to", end)
+		}
+
+		if err := out.Close(); err != nil {
+			return err
+		}
+		logger.InfoContext(cmd.Context(), false, "Parquet export completed")
+
+		return nil
+	},
+}
+
+// exportRange reads blocks and transactions between from and to and writes them to sink.
+func exportRange(cmd *cobra.Command, database db.Database, out db.Sink, from, to uint32) error {
+	blocks := make([]*schema.Block, 0)
+	if err := database.FindRange(cmd.Context(), schema.BlockTableName, "height", from, to, &blocks); err != nil {
+		return err
+	}
+
+	txs := make([]*schema.Transaction, 0)
+	if err := database.FindRange(cmd.Context(), schema.TransactionsTableName, "block_height", from, to,
+		&txs); err != nil {
+		return err
+	}
+
+	txsByHeight := make(map[uint32][]*schema.Transaction, len(blocks))
+	for _, tx := range txs {
+		txsByHeight[tx.BlockHeight] = append(txsByHeight[tx.BlockHeight], tx)
+	}
+
+	for _, b := range blocks {
+		if err := out.WriteBlock(cmd.Context(), b, txsByHeight[b.Height]); err != nil {
+			return err
+		}
+
+		if err := out.Checkpoint(cmd.Context(), b.Height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findDB returns database config by name, empty name returns first database.
+func findDB(cfg *schema.Config, name string) (*schema.DB, error) {
+	if len(cfg.DBS) == 0 {
+		return nil, errors.New("dbs is null")
+	}
+
+	if len(name) == 0 {
+		return cfg.DBS[0], nil
+	}
+
+	for _, d := range cfg.DBS {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("database %s not found in config", name)
+}
@@ -1,73 +1,197 @@
 package commands
 
 import (
+	"fmt"
 	"github.com/Pactus-Contrib/Indexer/config"
 	"github.com/Pactus-Contrib/Indexer/db"
 	"github.com/Pactus-Contrib/Indexer/logging"
 	"github.com/Pactus-Contrib/Indexer/schema"
 	"github.com/spf13/cobra"
+	"strconv"
+	"text/tabwriter"
+	"time"
 )
 
+var resetCursor bool
+
 func init() {
+	for _, cmd := range []*cobra.Command{migrationCmd, migrationUpCmd} {
+		cmd.Flags().BoolVar(&resetCursor, "reset-cursor", false,
+			"set last_block_height of an existing indexer back to last_block_height of config, "+
+				"blocks and transactions from that height on are deleted")
+	}
+
+	migrationCmd.AddCommand(migrationUpCmd, migrationDownCmd, migrationStatusCmd, migrationToCmd)
 	rootCmd.AddCommand(migrationCmd)
 }
 
 var migrationCmd = &cobra.Command{
 	Use:   "migrate",
-	Short: "migrate database schema",
+	Short: "migrate database schema to latest version, same as migrate up",
+	RunE:  migrateUp,
+}
+
+var migrationUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "apply all pending migrations and register indexer",
+	Args:  cobra.NoArgs,
+	RunE:  migrateUp,
+}
+
+var migrationDownCmd = &cobra.Command{
+	Use:   "down N",
+	Short: "revert last N applied migrations of every database",
+	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := config.New(configPath)
+		n, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil || n == 0 {
+			return fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+
+		p, logger, _, err := migrationPool(cmd)
 		if err != nil {
 			return err
 		}
+		defer p.Close()
 
-		if err := cfg.Validate(); err != nil {
+		if err := p.MigrateDown(cmd.Context(), uint(n)); err != nil {
 			return err
 		}
+		logger.InfoContext(cmd.Context(), false, "Migrations reverted", "count", n)
 
-		logger, err := defaultLogging()
-		if cfg.Logging != nil {
-			logOpt := logging.Options{
-				Development:  false,
-				Debug:        false,
-				EnableCaller: false,
-				SkipCaller:   0,
-			}
+		return nil
+	},
+}
 
-			logger, err = logging.New(cfg.Logging.Handler, logOpt)
+var migrationToCmd = &cobra.Command{
+	Use:   "to VERSION",
+	Short: "migrate every database up or down to VERSION, 0 reverts all migrations",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid migration version %q", args[0])
 		}
+
+		p, logger, _, err := migrationPool(cmd)
 		if err != nil {
 			return err
 		}
+		defer p.Close()
 
-		logger.InfoContext(cmd.Context(), false, "Migration started")
-		p := db.NewPool(logger)
-		logger.InfoContext(cmd.Context(), false, "Created new database pool")
+		if err := p.MigrateTo(cmd.Context(), uint(version)); err != nil {
+			return err
+		}
+		logger.InfoContext(cmd.Context(), false, "Migrated", "version", version)
+
+		return nil
+	},
+}
+
+var migrationStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show applied and pending migrations of every database",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, _, cfg, err := migrationPool(cmd)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
 
+		status, err := p.MigrationStatus(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "DATABASE\tVERSION\tDESCRIPTION\tSTATE\tAPPLIED AT")
 		for _, d := range cfg.DBS {
-			switch d.Engine {
-			case schema.MARIADB, schema.MYSQL, schema.POSTGRESQL:
-				sql, err := db.NewSQL(d)
-				if err != nil {
-					return err
-				}
-				p.RegisterEngine(sql)
-			case schema.MONGODB:
-				mgo, err := db.NewMongodb(cmd.Context(), d)
-				if err != nil {
-					return err
+			st, ok := status[d.Name]
+			if !ok {
+				_, _ = fmt.Fprintf(w, "%s\t-\t-\tunversioned\t-\n", d.Name)
+				continue
+			}
+
+			for _, s := range st {
+				state, appliedAt := "pending", "-"
+				if s.Applied {
+					state = "applied"
+					if !s.AppliedAt.IsZero() {
+						appliedAt = s.AppliedAt.Format(time.RFC3339)
+					}
 				}
-				p.RegisterEngine(mgo)
+				_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", d.Name, s.Version, s.Description, state, appliedAt)
 			}
 		}
 
-		logger.InfoContext(cmd.Context(), false, "All database registered in pool")
-		logger.InfoContext(cmd.Context(), false, "Migration in process...")
-		if err := p.Migration(cmd.Context(), cfg.IndexerUuid, cfg.LastBlockHeight); err != nil {
-			return err
+		return w.Flush()
+	},
+}
+
+func migrateUp(cmd *cobra.Command, _ []string) error {
+	p, logger, cfg, err := migrationPool(cmd)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	logger.InfoContext(cmd.Context(), false, "Migration in process...")
+	reports, err := p.Migration(cmd.Context(), cfg.IndexerUuid, cfg.LastBlockHeight, resetCursor)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		logger.InfoContext(cmd.Context(), false, "Database migrated", "db", r.Database, "applied", r.Applied,
+			"version", r.Version, "indexer", r.Indexer, "last_block_height", r.LastBlockHeight)
+
+		if r.Indexer == db.IndexerSkipped && r.LastBlockHeight != cfg.LastBlockHeight {
+			logger.WarnContext(cmd.Context(), false, "Indexer already exists, its cursor is kept, "+
+				"use --reset-cursor to start from last_block_height of config", "db", r.Database,
+				"last_block_height", r.LastBlockHeight, "config_last_block_height", cfg.LastBlockHeight)
+		}
+		if r.Indexer == db.IndexerCursorReset {
+			logger.WarnContext(cmd.Context(), false, "Indexer cursor reset", "db", r.Database,
+				"from", r.PrevBlockHeight, "to", r.LastBlockHeight)
 		}
-		logger.InfoContext(cmd.Context(), false, "Migration completed")
+	}
+	logger.InfoContext(cmd.Context(), false, "Migration completed", "version", db.LatestMigration())
 
-		return nil
-	},
+	return nil
+}
+
+// migrationPool loads config and registers every database in a new pool.
+func migrationPool(cmd *cobra.Command) (*db.Pool, logging.Logger, *schema.Config, error) {
+	cfg, err := config.New(configPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	logger, err := defaultLogging()
+	if cfg.Logging != nil {
+		logOpt := logging.Options{
+			Development:  false,
+			Debug:        false,
+			EnableCaller: false,
+			SkipCaller:   0,
+		}
+
+		logger, err = logging.New(cfg.Logging.Handler, logOpt)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	p, err := newPool(cmd.Context(), cfg, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logger.InfoContext(cmd.Context(), false, "All database registered in pool")
+
+	return p, logger, cfg, nil
 }
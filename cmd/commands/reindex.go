@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/core"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/spf13/cobra"
+	"slices"
+	"strings"
+)
+
+const _reindexProgressEvery = 1000
+
+var (
+	reindexFrom   uint32
+	reindexTo     uint32
+	reindexDBs    []string
+	reindexTables []string
+)
+
+func init() {
+	reindexCmd.Flags().Uint32Var(&reindexFrom, "from", 0, "first block height")
+	reindexCmd.Flags().Uint32Var(&reindexTo, "to", 0,
+		"last block height, default is lowest last indexed height between chosen databases")
+	reindexCmd.Flags().StringSliceVar(&reindexDBs, "db", nil, "databases to reindex, default is every database")
+	reindexCmd.Flags().StringSliceVar(&reindexTables, "tables", db.HeightTables(), "tables to reindex")
+	_ = reindexCmd.MarkFlagRequired("from")
+
+	rootCmd.AddCommand(reindexCmd)
+}
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "delete a height range from databases and index it again from the node, cursors don't move",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, table := range reindexTables {
+			if !slices.Contains(db.HeightTables(), table) {
+				return fmt.Errorf("table %s can't be reindexed, tables are %s", table,
+					strings.Join(db.HeightTables(), ","))
+			}
+		}
+
+		p, cfg, err := databasePool(cmd)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+
+		names := reindexDBs
+		if len(names) == 0 {
+			names = p.Names()
+		}
+
+		cursors, err := p.GetCursor(cmd.Context(), cfg.IndexerUuid)
+		if err != nil {
+			return err
+		}
+
+		// heights past the cursor are written by sync, reindexing them would write them twice
+		indexed := uint32(0)
+		for i, name := range names {
+			next, ok := cursors[name]
+			if !ok {
+				return fmt.Errorf("database %s not found in config", name)
+			}
+			if next == 0 {
+				next = 1
+			}
+			if i == 0 || next-1 < indexed {
+				indexed = next - 1
+			}
+		}
+
+		to := reindexTo
+		if to == 0 {
+			to = indexed
+		}
+
+		if reindexFrom == 0 || reindexFrom > to {
+			return fmt.Errorf("invalid range %d..%d", reindexFrom, to)
+		}
+		if to > indexed {
+			return fmt.Errorf("height %d is not indexed yet in every chosen database, last indexed height is %d",
+				to, indexed)
+		}
+
+		pactus, err := client.NewPactus(cmd.Context(), cfg.Pactus.RPC)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(out, "Reindexing %s of %s, heights %d..%d\n", strings.Join(reindexTables, ","),
+			strings.Join(names, ","), reindexFrom, to)
+
+		if err := core.Reindex(cmd.Context(), pactus, p, reindexFrom, to, reindexTables, names,
+			func(height uint32) {
+				if (height-reindexFrom+1)%_reindexProgressEvery == 0 {
+					_, _ = fmt.Fprintf(out, "Reindexed %d/%d heights\n", height-reindexFrom+1, to-reindexFrom+1)
+				}
+			}); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(out, "Reindex completed, %d heights\n", to-reindexFrom+1)
+
+		return nil
+	},
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/config"
+	"github.com/Pactus-Contrib/Indexer/core"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/webhook"
+	"strings"
+	"sync"
+	"time"
+)
+
+const _defaultWatchInterval = 2 * time.Second
+
+// reloader applies safe config changes to a running indexer, a config with unsafe changes is rejected as a whole.
+type reloader struct {
+	mu         sync.Mutex
+	path       string
+	cfg        *schema.Config
+	logger     logging.Logger
+	sync       *core.Sync
+	dispatcher *webhook.Dispatcher
+}
+
+func (r *reloader) reload(trigger config.Trigger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx := context.Background()
+	r.logger.InfoContext(ctx, false, "Reloading config", "config", r.path, "trigger", trigger)
+
+	next, err := config.New(r.path)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, false, "Config reload rejected, config is invalid", "err", err)
+		return
+	}
+
+	if changes := config.UnsafeChanges(r.cfg, next); len(changes) != 0 {
+		r.logger.ErrorContext(ctx, false, "Config reload rejected, restart indexer to apply these changes",
+			"changes", strings.Join(changes, "; "))
+		return
+	}
+
+	if l, ok := r.logger.(logging.Reloader); ok {
+		if err := l.Reload(runLogging(next.Logging)); err != nil {
+			r.logger.ErrorContext(ctx, false, "Config reload rejected, can't apply logging", "err", err)
+			return
+		}
+	}
+
+	if next.SyncIntervalPerBlock != r.cfg.SyncIntervalPerBlock {
+		r.sync.SetInterval(time.Duration(next.SyncIntervalPerBlock) * time.Second)
+	}
+
+	if r.dispatcher != nil {
+		var targets []*schema.Webhook
+		if next.Webhooks != nil {
+			targets = next.Webhooks.Targets
+		}
+		r.dispatcher.SetTargets(targets)
+	}
+
+	r.cfg = next
+	r.logger.InfoContext(ctx, false, "Config reloaded", "config", r.path)
+}
@@ -1,15 +1,34 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Pactus-Contrib/Indexer/client"
 	"github.com/Pactus-Contrib/Indexer/config"
+	"github.com/Pactus-Contrib/Indexer/core"
+	"github.com/Pactus-Contrib/Indexer/db"
 	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/metrics"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/server"
+	"github.com/Pactus-Contrib/Indexer/sink"
+	"github.com/Pactus-Contrib/Indexer/tracing"
 	"github.com/Pactus-Contrib/Indexer/version"
+	"github.com/Pactus-Contrib/Indexer/webhook"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+var dryRun bool
+
 func init() {
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"index into in-memory databases, nothing is persisted and sinks and webhooks are disabled")
 	rootCmd.AddCommand(runCmd)
 }
 
@@ -26,48 +45,242 @@ var runCmd = &cobra.Command{
 			return err
 		}
 
-		logger, err := defaultLogging()
-		if cfg.Logging != nil {
-			logOpt := logging.Options{
-				Development:  false,
-				Debug:        cfg.Logging.Debug,
-				EnableCaller: cfg.Logging.EnableCaller,
-				SkipCaller:   3,
-			}
+		logger, err := logging.New(runLogging(cfg.Logging))
+		if err != nil {
+			return err
+		}
 
-			if len(cfg.Logging.SentryDSN) != 0 {
-				logOpt.Sentry = &logging.SentryConfig{
-					DSN:              cfg.Logging.SentryDSN,
-					AttachStacktrace: true,
-					ServerName:       version.Application,
-					Environment:      logging.PRODUCTION,
-					Release:          version.Semantic(),
-					Dist:             version.Full(),
-					EnableTracing:    true,
-					Debug:            true,
-					TracesSampleRate: 1.0,
-				}
+		flushTraces := func(context.Context) error { return nil }
+		if cfg.Tracing != nil {
+			flushTraces, err = tracing.Setup(cmd.Context(), cfg.Tracing)
+			if err != nil {
+				return err
 			}
-
-			logger, err = logging.New(cfg.Logging.Handler, logOpt)
+			logger.InfoContext(cmd.Context(), false, "Tracing enabled", "exporter", cfg.Tracing.Exporter)
 		}
+
+		timeout := core.ShutdownTimeout(cfg)
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		context.AfterFunc(ctx, func() {
+			// a second signal kills the process without waiting for shutdown
+			stop()
+			logger.Info(false, "Shutting down", "timeout", timeout.String())
+		})
+
+		pactus, err := client.NewPactus(ctx, cfg.Pactus.RPC)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println(logger)
-
-		pactus, err := client.NewPactus(cmd.Context(), cfg.Pactus.RPC)
+		var p *db.Pool
+		if dryRun {
+			p, err = newDryRunPool(ctx, cfg, logger)
+		} else {
+			p, err = newPool(ctx, cfg, logger)
+		}
 		if err != nil {
 			return err
 		}
 
-		fmt.Println(pactus)
+		gp, gpCtx := errgroup.WithContext(ctx)
+		var dispatcher *webhook.Dispatcher
+
+		if !dryRun && cfg.Webhooks != nil && len(cfg.Webhooks.Targets) != 0 {
+			outboxDB := cfg.DBS[0].Name
+			if len(cfg.Webhooks.OutboxDB) != 0 {
+				outboxDB = cfg.Webhooks.OutboxDB
+			}
+
+			outbox, ok := p.Executor(outboxDB)
+			if !ok {
+				return errors.Join(p.Close(), fmt.Errorf("webhook outbox database %s is not registered", outboxDB))
+			}
+
+			dispatcher = webhook.NewDispatcher(outbox, cfg.Webhooks.Targets, logger)
+			if err := p.SetOutbox(outboxDB, dispatcher); err != nil {
+				return errors.Join(p.Close(), err)
+			}
+			gp.Go(func() error {
+				// undelivered rows stay in outbox and are delivered after restart
+				if err := dispatcher.Run(gpCtx); !errors.Is(err, context.Canceled) {
+					return err
+				}
+
+				return nil
+			})
+			logger.InfoContext(ctx, false, "Webhook dispatcher started", "outbox", outboxDB)
+		}
+
+		if !dryRun {
+			gp.Go(func() error {
+				if err := p.KeepAlive(gpCtx, 0); !errors.Is(err, context.Canceled) {
+					return err
+				}
+
+				return nil
+			})
+		}
+
+		if cfg.HTTP != nil && len(cfg.HTTP.Listen) != 0 {
+			srv := server.New(cfg.HTTP.Listen, logger)
+			srv.Handle("/metrics", metrics.Handler())
+			srv.Handle("/healthz", server.Healthz())
+
+			checkers := []server.Checker{server.DatabaseCheck(p), server.PactusCheck(pactus)}
+			if cfg.HTTP.ReadyMaxLag != 0 {
+				checkers = append(checkers, server.LagCheck(uint32(cfg.HTTP.ReadyMaxLag)))
+			}
+			srv.Handle("/readyz", server.Readyz(checkers...))
+			gp.Go(func() error {
+				return srv.Run(gpCtx)
+			})
+		}
+
+		if cfg.GapRepair != nil {
+			gaps := core.NewGapRepair(cfg, pactus, p, logger)
+			gp.Go(func() error {
+				if err := gaps.Run(gpCtx); !errors.Is(err, context.Canceled) {
+					return err
+				}
+
+				return nil
+			})
+			logger.InfoContext(ctx, false, "Gap repair started")
+		}
+
+		sync := core.NewSync(cfg, pactus, p, logger)
+		gp.Go(func() error {
+			return sync.Start(gpCtx)
+		})
+		r := &reloader{path: configPath, cfg: cfg, logger: logger, sync: sync, dispatcher: dispatcher}
+		go config.Watch(gpCtx, configPath, _defaultWatchInterval, r.reload)
+		logger.InfoContext(ctx, false, "Indexer started", "config", configPath)
+
+		err = gp.Wait()
 
-		return nil
+		return errors.Join(err, shutdown(logger, p, flushTraces, timeout))
 	},
 }
 
+// shutdown flushes spans and sentry events, then closes databases and sinks, sinks flush buffered rows on
+// close. Its deadline starts when it's called, also when a worker failed without a signal, so a stuck backend
+// can't block exit.
+func shutdown(logger logging.Logger, p *db.Pool, flushTraces func(context.Context) error,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		traceErr := flushTraces(ctx)
+		if client := logger.GetSentryClient(); client != nil {
+			client.Flush(timeout)
+		}
+		done <- errors.Join(traceErr, p.Close())
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			logger.InfoContext(ctx, false, "Indexer stopped")
+		}
+
+		return err
+	case <-ctx.Done():
+		return errors.New("shutdown deadline exceeded, databases and sinks may not be closed")
+	}
+}
+
+// newPool connects to every configured database and sink and registers them in a new pool.
+func newPool(ctx context.Context, cfg *schema.Config, logger logging.Logger) (*db.Pool, error) {
+	p := db.NewPool(logger)
+	if err := p.Open(ctx, cfg.DBS...); err != nil {
+		return nil, err
+	}
+
+	for _, s := range cfg.Sinks {
+		switch s.Type {
+		case schema.FILE:
+			file, err := sink.NewFile(s)
+			if err != nil {
+				return nil, errors.Join(p.Close(), err)
+			}
+			p.RegisterSink(file)
+		case schema.NDJSON, schema.CSV:
+			segment, err := sink.NewSegment(s)
+			if err != nil {
+				return nil, errors.Join(p.Close(), err)
+			}
+			p.RegisterSink(segment)
+		case schema.PARQUET:
+			pq, err := sink.NewParquet(s)
+			if err != nil {
+				return nil, errors.Join(p.Close(), err)
+			}
+			p.RegisterSink(pq)
+		}
+	}
+
+	return p, nil
+}
+
+// newDryRunPool registers an in-memory database in place of every configured database and migrates them,
+// sinks are skipped.
+func newDryRunPool(ctx context.Context, cfg *schema.Config, logger logging.Logger) (*db.Pool, error) {
+	p := db.NewPool(logger)
+
+	names := make([]string, 0, len(cfg.DBS))
+	for _, d := range cfg.DBS {
+		names = append(names, d.Name)
+	}
+	if len(names) == 0 {
+		names = append(names, "dry-run")
+	}
+
+	for _, name := range names {
+		p.RegisterEngine(db.NewMemory(&schema.DB{Name: name, Type: schema.SQL, Engine: schema.MEMORY}))
+	}
+
+	if _, err := p.Migration(ctx, cfg.IndexerUuid, cfg.LastBlockHeight, false); err != nil {
+		return nil, err
+	}
+
+	logger.InfoContext(ctx, false, "Dry run, blocks are indexed in memory only", "dbs", names)
+
+	return p, nil
+}
+
+// runLogging returns logger options of run command, console handler is used when logging section is missing.
+func runLogging(cfg *schema.Logging) (logging.HandleType, logging.Options) {
+	if cfg == nil {
+		return logging.ConsoleHandler, logging.Options{EnableCaller: true, SkipCaller: 3}
+	}
+
+	logOpt := logging.Options{
+		Development:  false,
+		Debug:        cfg.Debug,
+		EnableCaller: cfg.EnableCaller,
+		SkipCaller:   3,
+	}
+
+	if len(cfg.SentryDSN) != 0 {
+		logOpt.Sentry = &logging.SentryConfig{
+			DSN:              cfg.SentryDSN,
+			AttachStacktrace: true,
+			ServerName:       version.Application,
+			Environment:      logging.PRODUCTION,
+			Release:          version.Semantic(),
+			Dist:             version.Full(),
+			EnableTracing:    true,
+			Debug:            true,
+			TracesSampleRate: 1.0,
+		}
+	}
+
+	return cfg.Handler, logOpt
+}
+
 func defaultLogging() (logging.Logger, error) {
 	return logging.New(logging.ConsoleHandler, logging.Options{
 		Development:  false,
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingSink fails to flush its rows on Close.
+type failingSink struct{}
+
+func (failingSink) Name() string { return "parquet" }
+
+func (failingSink) WriteBlock(context.Context, *schema.Block, []*schema.Transaction) error {
+	return nil
+}
+
+func (failingSink) Rollback(context.Context, uint32) error   { return nil }
+func (failingSink) Checkpoint(context.Context, uint32) error { return nil }
+func (failingSink) Cursor(context.Context) (uint32, error)   { return 0, nil }
+func (failingSink) Close() error                             { return errors.New("disk full") }
+
+var _ db.Sink = failingSink{}
+
+func TestShutdown_FlushFails(t *testing.T) {
+	p := dbtest.MemoryPool(t, "memory")
+	p.RegisterSink(failingSink{})
+
+	flushTraces := func(context.Context) error { return errors.New("collector unreachable") }
+
+	err := shutdown(dbtest.Logger(t), p, flushTraces, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "disk full") ||
+		!strings.Contains(err.Error(), "collector unreachable") {
+		t.Fatalf("expected sink and trace flush errors, got %v", err)
+	}
+}
+
+func TestShutdown_Deadline(t *testing.T) {
+	p := dbtest.MemoryPool(t, "memory")
+
+	// the deadline starts with shutdown, without a canceled run context
+	stuck := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+
+		return ctx.Err()
+	}
+
+	start := time.Now()
+	if err := shutdown(dbtest.Logger(t), p, stuck, 20*time.Millisecond); err == nil {
+		t.Fatal("expected deadline error")
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected shutdown to give up at deadline, took %s", elapsed)
+	}
+}
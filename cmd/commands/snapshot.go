@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/config"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/snapshot"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+var (
+	snapshotDB     string
+	snapshotHeight uint32
+	snapshotOut    string
+	restoreDBs     []string
+)
+
+func init() {
+	snapshotCreateCmd.Flags().StringVar(&snapshotDB, "db", "", "source database name, default is first database")
+	snapshotCreateCmd.Flags().Uint32Var(&snapshotHeight, "height", 0,
+		"last block height in snapshot, default is last indexed height")
+	snapshotCreateCmd.Flags().StringVarP(&snapshotOut, "out", "o", "",
+		"archive path, default is snapshot-<height>.jsonl.gz")
+	snapshotRestoreCmd.Flags().StringSliceVar(&restoreDBs, "db", nil,
+		"databases to restore into, default is every database")
+
+	snapshotCmd.AddCommand(snapshotCreateCmd, snapshotRestoreCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "dump indexed blocks and transactions to an archive or restore them into any engine",
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "dump blocks and transactions up to a height into a compressed archive",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.New(configPath)
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		source, err := findDB(cfg, snapshotDB)
+		if err != nil {
+			return err
+		}
+
+		database, err := db.Open(cmd.Context(), source)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		height := snapshotHeight
+		if height == 0 {
+			var indexer schema.Indexer
+			if err := database.FindOne(cmd.Context(), schema.IndexerTableName, "index_id", cfg.IndexerUuid,
+				&indexer); err != nil {
+				return err
+			}
+			if indexer.LastBlockHeight <= 1 {
+				return fmt.Errorf("no block is indexed in %s yet", source.Name)
+			}
+			height = uint32(indexer.LastBlockHeight) - 1
+		}
+
+		out := snapshotOut
+		if len(out) == 0 {
+			out = fmt.Sprintf("snapshot-%d.jsonl.gz", height)
+		}
+
+		// archive gets its name only when it's complete
+		tmp := out + ".tmp"
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+
+		summary, err := snapshot.Create(cmd.Context(), database, f, cfg.IndexerUuid, height)
+		if err = errors.Join(err, f.Close()); err != nil {
+			return errors.Join(err, os.Remove(tmp))
+		}
+
+		if err := os.Rename(tmp, out); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Snapshot of %s at height %d written to %s, %d blocks and %d "+
+			"transactions\n", source.Name, height, out, summary.Blocks, summary.Transactions)
+
+		return nil
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "load a snapshot into databases and move their cursor past its height, rerun to resume a failed restore",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.New(configPath)
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// whole archive is read once before writing, so a truncated one leaves databases untouched
+		m, summary, err := snapshot.Check(f)
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		targets := cfg.DBS
+		if len(restoreDBs) != 0 {
+			targets = make([]*schema.DB, 0, len(restoreDBs))
+			for _, name := range restoreDBs {
+				if len(name) == 0 {
+					continue
+				}
+
+				d, err := findDB(cfg, name)
+				if err != nil {
+					return err
+				}
+				targets = append(targets, d)
+			}
+		}
+
+		logger, err := defaultLogging()
+		if err != nil {
+			return err
+		}
+
+		p := db.NewPool(logger)
+		if err := p.Open(cmd.Context(), targets...); err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+
+		if _, err := p.Migration(cmd.Context(), cfg.IndexerUuid, cfg.LastBlockHeight, false); err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if m.IndexerUuid != cfg.IndexerUuid {
+			_, _ = fmt.Fprintf(out, "Snapshot of indexer %s is restored as indexer %s\n", m.IndexerUuid,
+				cfg.IndexerUuid)
+		}
+
+		if _, _, err := snapshot.Restore(cmd.Context(), f, p, cfg.IndexerUuid); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(out, "Snapshot of %s at height %d restored, %d blocks and %d transactions\n", m.Source,
+			m.Height, summary.Blocks, summary.Transactions)
+
+		return nil
+	},
+}
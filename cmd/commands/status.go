@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/config"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	pactusgrpc "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"github.com/spf13/cobra"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var statusJSON bool
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print status as json")
+	rootCmd.AddCommand(statusCmd)
+}
+
+// dbStatus is progress of the indexer in one database, Lag is nil when node height is unknown. Error is set when
+// the database can't be read, other fields except Name and Engine are empty then.
+type dbStatus struct {
+	Name         string    `json:"name"`
+	Engine       string    `json:"engine"`
+	LastHeight   uint32    `json:"last_height"`
+	Lag          *uint32   `json:"lag"`
+	IndexedAt    time.Time `json:"indexed_at"`
+	IndexedAge   float64   `json:"indexed_age_seconds"`
+	Blocks       int64     `json:"blocks"`
+	Transactions int64     `json:"transactions"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type statusReport struct {
+	NodeHeight *uint32     `json:"node_height"`
+	NodeError  string      `json:"node_error,omitempty"`
+	Databases  []*dbStatus `json:"databases"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show last indexed height, lag and row counts of every database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := commandConfig()
+		if err != nil {
+			return err
+		}
+
+		logger, err := defaultLogging()
+		if err != nil {
+			return err
+		}
+
+		p := db.NewPool(logger)
+		openErrs := openEach(cmd.Context(), p, cfg.DBS)
+		defer func() { _ = p.Close() }()
+
+		report := &statusReport{}
+		if height, err := nodeHeight(cmd.Context(), cfg.Pactus.RPC); err != nil {
+			report.NodeError = err.Error()
+		} else {
+			report.NodeHeight = &height
+		}
+		report.Databases = indexerStatus(cmd.Context(), p, cfg.DBS, openErrs, cfg.IndexerUuid, report.NodeHeight)
+
+		if statusJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+
+			return enc.Encode(report)
+		}
+
+		return printStatus(cmd.OutOrStdout(), report)
+	},
+}
+
+// openEach connects to every database on its own and registers reachable ones in p, so an unreachable database
+// doesn't hide status of the others. Errors of unreachable databases are returned by name.
+func openEach(ctx context.Context, p *db.Pool, dbs []*schema.DB) map[string]error {
+	openErrs := make(map[string]error)
+	mu := sync.Mutex{}
+
+	wg := sync.WaitGroup{}
+	for _, d := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			connCtx, cancel := context.WithTimeout(ctx, _defaultCheckTimeout)
+			defer cancel()
+			database, err := db.Open(connCtx, d)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				openErrs[d.Name] = err
+
+				return
+			}
+			p.RegisterEngine(database)
+		}()
+	}
+	wg.Wait()
+
+	return openErrs
+}
+
+// indexerStatus reads cursor and row counts of every database in order of dbs, a database which is unreachable
+// or can't be read is reported in its row. Lag is set only when nodeHeight is known.
+func indexerStatus(ctx context.Context, p *db.Pool, dbs []*schema.DB, openErrs map[string]error, indexerId string,
+	nodeHeight *uint32) []*dbStatus {
+	indexers := make(map[string]db.IndexerResult)
+	for _, r := range p.GetIndexers(ctx, indexerId) {
+		indexers[r.Name] = r
+	}
+
+	statuses := make([]*dbStatus, len(dbs))
+	wg := sync.WaitGroup{}
+	for i, d := range dbs {
+		if err, ok := openErrs[d.Name]; ok {
+			statuses[i] = &dbStatus{Name: d.Name, Engine: d.Engine.String(), Error: "unreachable: " + err.Error()}
+
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			st, err := databaseStatus(ctx, p, indexers[d.Name])
+			if err != nil {
+				statuses[i] = &dbStatus{Name: d.Name, Engine: d.Engine.String(), Error: err.Error()}
+
+				return
+			}
+
+			if nodeHeight != nil {
+				lag := uint32(0)
+				if *nodeHeight > st.LastHeight {
+					lag = *nodeHeight - st.LastHeight
+				}
+				st.Lag = &lag
+			}
+			statuses[i] = st
+		}()
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// databaseStatus reads row counts of the database of indexer and derives its progress.
+func databaseStatus(ctx context.Context, p *db.Pool, indexer db.IndexerResult) (*dbStatus, error) {
+	if indexer.Err != nil {
+		return nil, indexer.Err
+	}
+
+	database, ok := p.Engine(indexer.Name)
+	if !ok {
+		return nil, fmt.Errorf("database %s is not registered", indexer.Name)
+	}
+
+	var err error
+	st := &dbStatus{Name: indexer.Name, Engine: indexer.Engine, IndexedAt: indexer.Indexer.IndexedAt}
+	if st.Blocks, err = database.Count(ctx, schema.BlockTableName); err != nil {
+		return nil, err
+	}
+	if st.Transactions, err = database.Count(ctx, schema.TransactionsTableName); err != nil {
+		return nil, err
+	}
+
+	// cursor is the next height to fetch
+	if indexer.Indexer.LastBlockHeight > 0 {
+		st.LastHeight = uint32(indexer.Indexer.LastBlockHeight - 1)
+	}
+	if !st.IndexedAt.IsZero() {
+		st.IndexedAge = time.Since(st.IndexedAt).Seconds()
+	}
+
+	return st, nil
+}
+
+func printStatus(out io.Writer, report *statusReport) error {
+	if report.NodeHeight != nil {
+		_, _ = fmt.Fprintf(out, "Node height: %d\n\n", *report.NodeHeight)
+	} else {
+		_, _ = fmt.Fprintf(out, "Node height: unknown (%s)\n\n", report.NodeError)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DATABASE\tENGINE\tLAST HEIGHT\tLAG\tINDEXED\tBLOCKS\tTRANSACTIONS\tERROR")
+	for _, st := range report.Databases {
+		if st.Error != "" {
+			// joined errors span lines, they are kept in one cell
+			_, _ = fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t%s\n", st.Name, st.Engine,
+				strings.ReplaceAll(st.Error, "\n", "; "))
+
+			continue
+		}
+
+		lag, indexed := "-", "never"
+		if st.Lag != nil {
+			lag = strconv.FormatUint(uint64(*st.Lag), 10)
+		}
+		if !st.IndexedAt.IsZero() {
+			indexed = (time.Duration(st.IndexedAge) * time.Second).String() + " ago"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t-\n", st.Name, st.Engine, st.LastHeight, lag, indexed,
+			st.Blocks, st.Transactions)
+	}
+
+	return w.Flush()
+}
+
+// nodeHeight returns last block height of pactus node.
+func nodeHeight(ctx context.Context, rpc string) (uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, _defaultCheckTimeout)
+	defer cancel()
+
+	pactus, err := client.NewPactus(ctx, rpc)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = pactus.Close() }()
+
+	info, err := pactus.Blockchain.GetBlockchainInfo(ctx, &pactusgrpc.GetBlockchainInfoRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	return info.GetLastBlockHeight(), nil
+}
+
+// databasePool loads config and connects to every configured database, sinks are not opened. Nothing is
+// logged so output of the command stays machine readable.
+func databasePool(cmd *cobra.Command) (*db.Pool, *schema.Config, error) {
+	cfg, err := commandConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger, err := defaultLogging()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := db.NewPool(logger)
+	if err := p.Open(cmd.Context(), cfg.DBS...); err != nil {
+		return nil, nil, err
+	}
+
+	return p, cfg, nil
+}
+
+// commandConfig loads and validates config of the command.
+func commandConfig() (*schema.Config, error) {
+	cfg, err := config.New(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strings"
+	"testing"
+)
+
+const testIndexerId = "8bc5d30c-1ccc-460c-adcc-508608b0c188"
+
+func TestIndexerStatus(t *testing.T) {
+	ctx := context.Background()
+
+	// empty is registered after migration, so it has no indexer row
+	p := dbtest.Pool(t, testIndexerId, dbtest.Memory("memory"))
+	p.RegisterEngine(dbtest.Memory("empty"))
+
+	dbs := []*schema.DB{
+		{Name: "down", Type: schema.SQL, Engine: schema.POSTGRESQL},
+		{Name: "memory", Type: schema.SQL, Engine: schema.MEMORY},
+		{Name: "empty", Type: schema.SQL, Engine: schema.MEMORY},
+	}
+	openErrs := map[string]error{"down": errors.New("connection refused")}
+	height := uint32(5)
+
+	statuses := indexerStatus(ctx, p, dbs, openErrs, testIndexerId, &height)
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(statuses))
+	}
+
+	if st := statuses[1]; st.Name != "memory" || st.Error != "" || st.Lag == nil || *st.Lag != 5 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	if st := statuses[2]; st.Name != "empty" || st.Error == "" || strings.HasPrefix(st.Error, "unreachable") {
+		t.Fatalf("expected read error of empty database, got %+v", st)
+	}
+
+	out := new(bytes.Buffer)
+	if err := printStatus(out, &statusReport{NodeHeight: &height, Databases: statuses}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected node height, header and 3 rows, got %q", out.String())
+	}
+
+	if fields := strings.Fields(lines[3]); fields[0] != "down" || fields[2] != "-" ||
+		!strings.Contains(lines[3], "unreachable: connection refused") {
+		t.Fatalf("expected unreachable row, got %q", lines[3])
+	}
+
+	if fields := strings.Fields(lines[4]); fields[0] != "memory" || fields[2] != "0" || fields[3] != "5" {
+		t.Fatalf("unexpected memory row %q", lines[4])
+	}
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/core"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/spf13/cobra"
+	"slices"
+	"text/tabwriter"
+)
+
+var (
+	verifyFrom   uint32
+	verifyTo     uint32
+	verifyRepair bool
+)
+
+func init() {
+	verifyCmd.Flags().Uint32Var(&verifyFrom, "from", 1, "first block height")
+	verifyCmd.Flags().Uint32Var(&verifyTo, "to", 0,
+		"last block height, default is lowest last indexed height between databases")
+	verifyCmd.Flags().BoolVar(&verifyRepair, "repair", false,
+		"replace differing blocks and their transactions with the ones fetched from node")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "compare indexed blocks and transactions of every database with the node",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, cfg, err := databasePool(cmd)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+
+		to := verifyTo
+		if to == 0 {
+			to, err = lowestIndexed(cmd.Context(), p, cfg.IndexerUuid)
+			if err != nil {
+				return err
+			}
+		}
+
+		if verifyFrom == 0 || verifyFrom > to {
+			return fmt.Errorf("invalid range %d..%d", verifyFrom, to)
+		}
+
+		pactus, err := client.NewPactus(cmd.Context(), cfg.Pactus.RPC)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "HEIGHT\tDATABASE\tFIELD\tNODE\tDATABASE VALUE\tREPAIRED")
+
+		found, heights, repaired := 0, 0, 0
+		err = core.Verify(cmd.Context(), pactus, p, verifyFrom, to, func(ctx context.Context, block *schema.Block,
+			txs []*schema.Transaction, mismatches []core.Mismatch) error {
+			found += len(mismatches)
+			heights++
+
+			fixed := "no"
+			if verifyRepair {
+				if err := p.ReplaceBlock(ctx, block, txs, mismatchedDBs(mismatches)...); err != nil {
+					return err
+				}
+				fixed = "yes"
+				repaired++
+			}
+
+			for _, m := range mismatches {
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", m.Height, m.DB, m.Field, orDash(m.Want),
+					orDash(m.Got), fixed)
+			}
+
+			return nil
+		})
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "\nVerified heights %d..%d, %d mismatches in %d heights, %d repaired\n",
+			verifyFrom, to, found, heights, repaired)
+
+		if heights != repaired {
+			return fmt.Errorf("%d heights differ from node, use --repair to replace them", heights-repaired)
+		}
+
+		return nil
+	},
+}
+
+// lowestIndexed returns last height indexed in every database.
+func lowestIndexed(ctx context.Context, p *db.Pool, indexerId string) (uint32, error) {
+	cursors, err := p.GetCursor(ctx, indexerId)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(cursors) == 0 {
+		return 0, errors.New("no database is configured")
+	}
+
+	next := slices.Min(valuesOf(cursors))
+	if next == 0 {
+		return 0, nil
+	}
+
+	return next - 1, nil
+}
+
+// mismatchedDBs returns names of databases which have a mismatch, without duplicates.
+func mismatchedDBs(mismatches []core.Mismatch) []string {
+	names := make([]string, 0, len(mismatches))
+	for _, m := range mismatches {
+		if !slices.Contains(names, m.DB) {
+			names = append(names, m.DB)
+		}
+	}
+
+	return names
+}
+
+func valuesOf(m map[string]uint32) []uint32 {
+	values := make([]uint32, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+
+	return values
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prefix of environment variables which override config fields. Names are yaml keys joined with
+// underscore, list items by their index, e.g. INDEXER_PACTUS_RPC or INDEXER_DBS_0_URI.
+const EnvPrefix = "INDEXER"
+
+const (
+	secretFileScheme = "file://"
+	redacted         = "REDACTED"
+)
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// applyEnv sets fields of struct v which have an environment variable, missing sections and list items are
+// created when a variable refers to them.
+func applyEnv(v reflect.Value, prefix string, environ map[string]string) error {
+	for i := 0; i < v.NumField(); i++ {
+		key := yamlKey(v.Type().Field(i))
+		if len(key) == 0 {
+			continue
+		}
+
+		name := prefix + "_" + strings.ToUpper(key)
+		field := v.Field(i)
+
+		switch {
+		case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+			if field.IsNil() {
+				if !hasPrefix(environ, name+"_") {
+					continue
+				}
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+
+			if err := applyEnv(field.Elem(), name, environ); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Pointer:
+			if n := itemCount(environ, name); n > field.Len() {
+				grown := reflect.MakeSlice(field.Type(), n, n)
+				reflect.Copy(grown, field)
+				field.Set(grown)
+			}
+
+			for j := 0; j < field.Len(); j++ {
+				item := field.Index(j)
+				if item.IsNil() {
+					item.Set(reflect.New(item.Type().Elem()))
+				}
+
+				if err := applyEnv(item.Elem(), fmt.Sprintf("%s_%d", name, j), environ); err != nil {
+					return err
+				}
+			}
+		default:
+			val, ok := environ[name]
+			if !ok {
+				continue
+			}
+
+			if err := setValue(field, val); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func setValue(field reflect.Value, val string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list of %s", field.Type().Elem())
+		}
+
+		items := strings.Split(val, ",")
+		s := reflect.MakeSlice(field.Type(), 0, len(items))
+		for _, item := range items {
+			s = reflect.Append(s, reflect.ValueOf(strings.TrimSpace(item)).Convert(field.Type().Elem()))
+		}
+		field.Set(s)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// resolve expands ${ENV_VAR} references in every string field and reads file:// references of secret fields.
+func resolve(v reflect.Value, path string) error {
+	return walkStrings(v, path, func(field reflect.Value, path string, secret bool) error {
+		var missing []string
+		val := envRef.ReplaceAllStringFunc(field.String(), func(ref string) string {
+			name := envRef.FindStringSubmatch(ref)[1]
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+
+			return env
+		})
+
+		if len(missing) != 0 {
+			return fmt.Errorf("%s: environment variable %s is not set", path, strings.Join(missing, ", "))
+		}
+
+		if secret && strings.HasPrefix(val, secretFileScheme) {
+			b, err := os.ReadFile(strings.TrimPrefix(val, secretFileScheme))
+			if err != nil {
+				return fmt.Errorf("%s: can't read secret: %w", path, err)
+			}
+			val = strings.TrimSpace(string(b))
+		}
+
+		field.SetString(val)
+
+		return nil
+	})
+}
+
+// redact hides secret fields, only credentials of urls are hidden so host stays visible.
+func redact(v reflect.Value) {
+	_ = walkStrings(v, "", func(field reflect.Value, _ string, secret bool) error {
+		if !secret || len(field.String()) == 0 {
+			return nil
+		}
+
+		u, err := url.Parse(field.String())
+		if err == nil && u.User != nil && len(u.Host) != 0 {
+			u.User = url.User(redacted)
+			field.SetString(u.String())
+			return nil
+		}
+
+		field.SetString(redacted)
+
+		return nil
+	})
+}
+
+// walkStrings calls fn for every string field of struct v with its yaml path, secret fields have secret tag.
+func walkStrings(v reflect.Value, path string, fn func(field reflect.Value, path string, secret bool) error) error {
+	var errs []error
+
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		key := yamlKey(sf)
+		if len(key) == 0 {
+			continue
+		}
+
+		fieldPath := key
+		if len(path) != 0 {
+			fieldPath = path + "." + key
+		}
+
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.String:
+			errs = append(errs, fn(field, fieldPath, sf.Tag.Get("secret") == "true"))
+		case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+			if !field.IsNil() {
+				errs = append(errs, walkStrings(field.Elem(), fieldPath, fn))
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Pointer:
+			for j := 0; j < field.Len(); j++ {
+				if !field.Index(j).IsNil() {
+					errs = append(errs, walkStrings(field.Index(j).Elem(), fmt.Sprintf("%s[%d]", fieldPath, j), fn))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func yamlKey(sf reflect.StructField) string {
+	key, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	if key == "-" {
+		return ""
+	}
+
+	return key
+}
+
+func environMap() map[string]string {
+	environ := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix+"_") {
+			environ[k] = v
+		}
+	}
+
+	return environ
+}
+
+func hasPrefix(environ map[string]string, prefix string) bool {
+	for k := range environ {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// itemCount returns length of list which environment variables refer to, e.g. 3 for INDEXER_DBS_2_URI.
+func itemCount(environ map[string]string, prefix string) int {
+	n := 0
+	for k := range environ {
+		rest, ok := strings.CutPrefix(k, prefix+"_")
+		if !ok {
+			continue
+		}
+
+		idx, _, _ := strings.Cut(rest, "_")
+		if i, err := strconv.Atoi(idx); err == nil && i+1 > n {
+			n = i + 1
+		}
+	}
+
+	return n
+}
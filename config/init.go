@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"gopkg.in/yaml.v3"
+)
+
+const header = `indexer config, see docs/config.sample.yml for every option.
+every field can be overridden by an INDEXER_ environment variable named by its keys,
+e.g. INDEXER_PACTUS_RPC or INDEXER_DBS_0_URI.`
+
+// comments are written next to keys of generated config, keys of list items have no index.
+var comments = map[string]string{
+	"last_block_height":       "first block height to sync, first block of chain is 1",
+	"sync_interval_per_block": "seconds between checks for new blocks, 3 to 86400",
+	"shutdown_timeout":        "seconds to finish in-flight block on SIGINT or SIGTERM, 0 is 30",
+	"indexer_uuid":            "identifies this indexer and its cursor in every database",
+	"pactus":                  "pactus node to index",
+	"pactus.rpc":              "grpc address of pactus node",
+	"dbs":                     "databases to store blocks and transactions, can be empty when sinks is set",
+	"dbs.name":                "unique name of database",
+	"dbs.type":                "sql or nosql",
+	"dbs.engine":              "mysql, psql, mariadb, mongodb, sqlite, clickhouse or memory",
+	"dbs.uri":                 "connection uri, accepts ${ENV_VAR} and file:// secrets",
+	"dbs.database":            "database name, required for mongodb",
+	"dbs.max_open_conns":      "0 is driver default",
+	"dbs.max_idle_conns":      "0 is driver default, not used by mongodb",
+	"dbs.conn_max_lifetime":   "seconds, not used by mongodb",
+	"dbs.connect_timeout":     "seconds, default is 2",
+	"dbs.query_timeout":       "seconds for every read, 0 is no timeout",
+	"dbs.write_timeout":       "seconds for every write, 0 is no timeout",
+	"dbs.write_concern":       "mongodb only, majority or number of nodes",
+	"dbs.read_preference":     "mongodb only, primary, primaryPreferred, secondary, secondaryPreferred or nearest",
+	"sinks":                   "optional file, ndjson, csv or parquet outputs",
+	"logging":                 "optional, errors are reported to sentry when sentry_dsn is set",
+	"logging.handler":         "0 console, 1 text, 2 json",
+	"logging.sentry_dsn":      "optional, accepts ${ENV_VAR} and file:// secrets",
+	"webhooks":                "optional, see docs/config.sample.yml",
+	"http":                    "optional, operational endpoints of run command",
+	"http.listen":             "address of /metrics, /healthz and /readyz, empty disables them",
+	"http.ready_max_lag":      "blocks behind chain tip before /readyz fails, 0 disables lag check",
+	"tracing":                 "optional opentelemetry traces of block fetch, decode and writes",
+	"tracing.exporter":        "otlp or file",
+
+	"gap_repair":                    "optional worker fetching again heights with a missing block or transactions",
+	"gap_repair.interval":           "seconds between scans of heights indexed since last scan, 0 is 300",
+	"gap_repair.batch_size":         "heights counted per query, rows are read only when counts differ, 0 is 1000",
+	"gap_repair.full_scan_interval": "seconds between scans from last_block_height, 0 is 86400",
+}
+
+// Commented returns cfg as yaml with a comment for every known key.
+func Commented(cfg *schema.Config) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return nil, err
+	}
+	comment(&doc, "")
+	doc.Content[0].HeadComment = header
+
+	buf := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func comment(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			keyPath := key.Value
+			if len(path) != 0 {
+				keyPath = path + "." + key.Value
+			}
+
+			if c, ok := comments[keyPath]; ok {
+				if value.Kind == yaml.ScalarNode {
+					value.LineComment = c
+				} else {
+					key.HeadComment = c
+				}
+			}
+			comment(value, keyPath)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, n := range node.Content {
+			comment(n, path)
+		}
+	}
+}
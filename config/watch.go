@@ -0,0 +1,145 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// Trigger tells what started a reload.
+type Trigger string
+
+const (
+	FileChanged Trigger = "file changed"
+	Hangup      Trigger = "SIGHUP"
+)
+
+// Watch calls reload when config file at path is modified or process gets SIGHUP until context is canceled.
+// File is polled on every interval, so editors which replace the file are noticed too.
+func Watch(ctx context.Context, path string, interval time.Duration, reload func(Trigger)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			last, _ = os.Stat(path)
+			reload(Hangup)
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil || !modified(last, info) {
+				continue
+			}
+			last = info
+			reload(FileChanged)
+		}
+	}
+}
+
+func modified(prev, next os.FileInfo) bool {
+	if prev == nil {
+		return true
+	}
+
+	return !prev.ModTime().Equal(next.ModTime()) || prev.Size() != next.Size()
+}
+
+// UnsafeChanges lists changes of next config which can't be applied to a running indexer. Logging,
+// sync_interval_per_block and webhook targets are safe, everything else needs a restart.
+func UnsafeChanges(prev, next *schema.Config) []string {
+	changes := make([]string, 0)
+
+	if prev.LastBlockHeight != next.LastBlockHeight {
+		changes = append(changes, "last_block_height changed")
+	}
+
+	if prev.ShutdownTimeout != next.ShutdownTimeout {
+		changes = append(changes, "shutdown_timeout changed")
+	}
+
+	if prev.IndexerUuid != next.IndexerUuid {
+		changes = append(changes, "indexer_uuid changed")
+	}
+
+	if !reflect.DeepEqual(prev.Pactus, next.Pactus) {
+		changes = append(changes, "pactus changed")
+	}
+
+	changes = append(changes, dbChanges(prev.DBS, next.DBS)...)
+
+	if !reflect.DeepEqual(prev.Sinks, next.Sinks) {
+		changes = append(changes, "sinks changed")
+	}
+
+	if !reflect.DeepEqual(prev.HTTP, next.HTTP) {
+		changes = append(changes, "http changed")
+	}
+
+	if !reflect.DeepEqual(prev.Tracing, next.Tracing) {
+		changes = append(changes, "tracing changed")
+	}
+
+	if !reflect.DeepEqual(prev.GapRepair, next.GapRepair) {
+		changes = append(changes, "gap_repair changed")
+	}
+
+	prevTargets, nextTargets := 0, 0
+	prevOutbox, nextOutbox := "", ""
+	if prev.Webhooks != nil {
+		prevTargets, prevOutbox = len(prev.Webhooks.Targets), prev.Webhooks.OutboxDB
+	}
+	if next.Webhooks != nil {
+		nextTargets, nextOutbox = len(next.Webhooks.Targets), next.Webhooks.OutboxDB
+	}
+
+	switch {
+	case prevTargets == 0 && nextTargets != 0:
+		changes = append(changes, "webhooks enabled, dispatcher is only started with indexer")
+	case prevOutbox != nextOutbox:
+		changes = append(changes, "webhooks.outbox_db changed")
+	}
+
+	return changes
+}
+
+func dbChanges(prev, next []*schema.DB) []string {
+	changes := make([]string, 0)
+
+	byName := make(map[string]*schema.DB, len(prev))
+	for _, d := range prev {
+		byName[d.Name] = d
+	}
+
+	seen := make(map[string]bool, len(next))
+	for _, d := range next {
+		seen[d.Name] = true
+
+		old, ok := byName[d.Name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("database %s added", d.Name))
+		case !reflect.DeepEqual(old, d):
+			changes = append(changes, fmt.Sprintf("database %s changed", d.Name))
+		}
+	}
+
+	for _, d := range prev {
+		if !seen[d.Name] {
+			changes = append(changes, fmt.Sprintf("database %s removed", d.Name))
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnsafeChanges(t *testing.T) {
+	prev := &schema.Config{
+		SyncIntervalPerBlock: 5,
+		DBS: []*schema.DB{
+			{Name: "lite", Type: schema.SQL, Engine: schema.SQLITE, URI: "file:./a.db"},
+			{Name: "mongo", Type: schema.NOSQL, Engine: schema.MONGODB, URI: "mongodb://localhost"},
+		},
+		Logging: &schema.Logging{Debug: false},
+	}
+
+	safe := *prev
+	safe.SyncIntervalPerBlock = 10
+	safe.Logging = &schema.Logging{Debug: true}
+	if changes := UnsafeChanges(prev, &safe); len(changes) != 0 {
+		t.Fatalf("expected no unsafe changes, got %v", changes)
+	}
+
+	unsafe := *prev
+	unsafe.DBS = []*schema.DB{
+		{Name: "lite", Type: schema.SQL, Engine: schema.SQLITE, URI: "file:./b.db"},
+		{Name: "memory", Type: schema.SQL, Engine: schema.MEMORY},
+	}
+	unsafe.Webhooks = &schema.Webhooks{Targets: []*schema.Webhook{{Name: "hook"}}}
+
+	want := []string{
+		"database lite changed",
+		"database memory added",
+		"database mongo removed",
+		"webhooks enabled, dispatcher is only started with indexer",
+	}
+	if changes := UnsafeChanges(prev, &unsafe); !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected %v, got %v", want, changes)
+	}
+}
+
+func TestWatch_FileChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("sync_interval_per_block: 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	triggers := make(chan Trigger, 1)
+	go Watch(ctx, path, 10*time.Millisecond, func(trigger Trigger) {
+		triggers <- trigger
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("sync_interval_per_block: 10\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case trigger := <-triggers:
+		if trigger != FileChanged {
+			t.Fatalf("unexpected trigger %s", trigger)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config change not noticed")
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"slices"
+	"strings"
+)
+
+const _compareChunkSize = 1000
+
+type digest = [sha256.Size]byte
+
+// Divergence lists heights where blocks or transactions of a database differ from the base database.
+type Divergence struct {
+	DB      string   `json:"db"`
+	Engine  string   `json:"engine"`
+	Heights []uint32 `json:"heights"`
+}
+
+// Compare computes digests of blocks and transactions of heights from..to in every database and returns
+// heights where a database differs from base. Ranges are compared in chunks by their digest first, a chunk whose
+// digest differs is bisected down to single heights. Engines can't hash rows the same way on their side, so rows
+// of a chunk are read once to build its digests and dropped afterwards. Missing rows differ like changed ones.
+func Compare(ctx context.Context, pool *db.Pool, from, to uint32, base string) ([]*Divergence, error) {
+	baseDB, ok := pool.Engine(base)
+	if !ok {
+		return nil, fmt.Errorf("database %s is not registered", base)
+	}
+
+	others := make([]db.Database, 0)
+	divergences := make([]*Divergence, 0)
+	for _, name := range pool.Names() {
+		if name == base {
+			continue
+		}
+
+		if d, ok := pool.Engine(name); ok {
+			others = append(others, d)
+			divergences = append(divergences, &Divergence{DB: d.Name(), Engine: d.Engine(), Heights: make([]uint32, 0)})
+		}
+	}
+
+	for start := from; start <= to; {
+		end := to
+		if to-start >= _compareChunkSize {
+			end = start + _compareChunkSize - 1
+		}
+
+		want, err := heightDigests(ctx, baseDB, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, other := range others {
+			got, err := heightDigests(ctx, other, start, end)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, idx := range bisect(want, got, 0, len(want)-1) {
+				divergences[i].Heights = append(divergences[i].Heights, start+uint32(idx))
+			}
+		}
+
+		if end == to {
+			break
+		}
+		start = end + 1
+	}
+
+	return divergences, nil
+}
+
+// bisect returns indexes between lo and hi inclusive where digests differ, equal halves are skipped.
+func bisect(want, got []digest, lo, hi int) []int {
+	if rangeDigest(want[lo:hi+1]) == rangeDigest(got[lo:hi+1]) {
+		return nil
+	}
+
+	if lo == hi {
+		return []int{lo}
+	}
+
+	mid := lo + (hi-lo)/2
+
+	return append(bisect(want, got, lo, mid), bisect(want, got, mid+1, hi)...)
+}
+
+func rangeDigest(digests []digest) digest {
+	h := sha256.New()
+	for _, d := range digests {
+		_, _ = h.Write(d[:])
+	}
+
+	return digest(h.Sum(nil))
+}
+
+// heightDigests returns digest of every height from..to of database.
+func heightDigests(ctx context.Context, database db.Database, from, to uint32) ([]digest, error) {
+	rows, err := readHeights(ctx, database, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make([]digest, 0, to-from+1)
+	for height := from; ; height++ {
+		digests = append(digests, heightDigest(rows.blocks[height], rows.txs[height]))
+
+		if height == to {
+			break
+		}
+	}
+
+	return digests, nil
+}
+
+// heightDigest hashes fields which every engine keeps the same way, ids are engine specific and times are
+// compared in seconds since engines store them with different precision.
+func heightDigest(block *schema.Block, txs []*schema.Transaction) digest {
+	h := sha256.New()
+
+	if block != nil {
+		_, _ = fmt.Fprintf(h, "block|%d|%s|%d|%d|%d|%d|%s|%s|%s|%s|%s|%d|%v|%v|%s\n", block.Height, block.Hash,
+			block.TotalTransactions, block.BlockTime, block.BlockReward, block.Version, block.PrevBlockHash,
+			block.StateRoot, block.SortitionSeed, block.ProposerAddress, block.CertificateHash, block.Round,
+			block.Committers, block.Absentees, block.Signature)
+	}
+
+	sorted := slices.Clone(txs)
+	slices.SortFunc(sorted, func(a, b *schema.Transaction) int { return strings.Compare(a.Hash, b.Hash) })
+
+	for _, tx := range sorted {
+		_, _ = fmt.Fprintf(h, "tx|%s|%d|%d|%s|%s|%s|%d|%d|%s|%d\n", tx.Hash, tx.BlockHeight, tx.Version, tx.Type,
+			tx.From, tx.To, tx.Value, tx.Fee, tx.Memo, tx.CreatedAt.Unix())
+	}
+
+	return digest(h.Sum(nil))
+}
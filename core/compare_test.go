@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strconv"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	p := dbtest.MemoryPool(t, "base", "same", "diverged")
+
+	for height := uint32(1); height <= 1200; height++ {
+		block := &schema.Block{Height: height, Hash: "block-" + strconv.Itoa(int(height)), TotalTransactions: 1}
+		txs := []*schema.Transaction{{Hash: "tx-" + strconv.Itoa(int(height)), BlockHeight: height, Value: 1}}
+
+		if err := p.SaveBlock(ctx, block, txs, []string{schema.BlockTableName, schema.TransactionsTableName},
+			"base", "same"); err != nil {
+			t.Fatal(err)
+		}
+
+		switch height {
+		case 7:
+			continue
+		case 1100:
+			txs[0].Value = 2
+		case 1200:
+			block.ProposerAddress = "other"
+		}
+
+		if err := p.SaveBlock(ctx, block, txs, []string{schema.BlockTableName, schema.TransactionsTableName},
+			"diverged"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	divergences, err := Compare(ctx, p, 1, 1200, "base")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(divergences) != 2 {
+		t.Fatalf("expected 2 databases, got %+v", divergences)
+	}
+
+	if divergences[0].DB != "same" || len(divergences[0].Heights) != 0 {
+		t.Fatalf("unexpected divergence %+v", divergences[0])
+	}
+
+	want := []uint32{7, 1100, 1200}
+	got := divergences[1].Heights
+	if divergences[1].DB != "diverged" || len(got) != len(want) {
+		t.Fatalf("expected heights %v, got %+v", want, divergences[1])
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected heights %v, got %v", want, got)
+		}
+	}
+
+	if _, err := Compare(ctx, p, 1, 2, "unknown"); err == nil {
+		t.Fatal("expected error for unknown base database")
+	}
+}
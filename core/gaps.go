@@ -0,0 +1,224 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/metrics"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"time"
+)
+
+const (
+	_defaultGapInterval     = 5 * time.Minute
+	_defaultGapBatchSize    = 1000
+	_defaultGapFullInterval = 24 * time.Hour
+)
+
+const (
+	GapMissingBlock        = "missing_block"
+	GapTransactionMismatch = "transaction_mismatch"
+)
+
+// GapRepair finds heights below the cursor of every database which have no block or whose transactions don't
+// match total_transactions of the block, and fetches them again from the node. Sync only writes at the cursor,
+// so they don't race. Every round scans heights indexed since the last one and every full interval all heights
+// from start height are scanned again, a height which fails to repair is scanned again in next round. Batches are
+// checked with counts in the database first, rows are only read for batches which don't add up.
+type GapRepair struct {
+	pactus       *client.Pactus
+	pool         *db.Pool
+	logger       logging.Logger
+	indexerId    string
+	startHeight  uint32
+	interval     time.Duration
+	fullInterval time.Duration
+	batchSize    uint32
+	scanned      map[string]uint32 // scanned height by database name, heights up to it are complete
+	fullScanAt   time.Time
+	now          func() time.Time
+}
+
+func NewGapRepair(cfg *schema.Config, pactus *client.Pactus, pool *db.Pool, logger logging.Logger) *GapRepair {
+	g := &GapRepair{
+		pactus:       pactus,
+		pool:         pool,
+		logger:       logger,
+		indexerId:    cfg.IndexerUuid,
+		startHeight:  uint32(cfg.LastBlockHeight),
+		interval:     _defaultGapInterval,
+		fullInterval: _defaultGapFullInterval,
+		batchSize:    _defaultGapBatchSize,
+		scanned:      make(map[string]uint32),
+		now:          time.Now,
+	}
+
+	if cfg.GapRepair != nil && cfg.GapRepair.Interval != 0 {
+		g.interval = time.Duration(cfg.GapRepair.Interval) * time.Second
+	}
+
+	if cfg.GapRepair != nil && cfg.GapRepair.FullScanInterval != 0 {
+		g.fullInterval = time.Duration(cfg.GapRepair.FullScanInterval) * time.Second
+	}
+
+	if cfg.GapRepair != nil && cfg.GapRepair.BatchSize != 0 {
+		g.batchSize = uint32(cfg.GapRepair.BatchSize)
+	}
+
+	return g
+}
+
+// Run scans every interval until ctx is canceled, a failed scan is logged and retried in next round.
+func (g *GapRepair) Run(ctx context.Context) error {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		repaired, err := g.Scan(ctx)
+		if err != nil && ctx.Err() == nil {
+			g.logger.ErrorContext(ctx, true, "gap scan failed", "err", err)
+		}
+		if repaired != 0 {
+			g.logger.InfoContext(ctx, false, "Gap scan completed", "repaired", repaired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan checks heights indexed since last scan in every database, or every height once full interval has passed,
+// and repairs broken ones, it returns number of repaired heights.
+func (g *GapRepair) Scan(ctx context.Context) (int, error) {
+	cursors, err := g.pool.GetCursor(ctx, g.indexerId)
+	if err != nil {
+		return 0, err
+	}
+
+	// rows deleted or lost after they were scanned are only found by scanning from start again
+	if now := g.now(); now.Sub(g.fullScanAt) >= g.fullInterval {
+		g.scanned = make(map[string]uint32)
+		g.fullScanAt = now
+	}
+
+	repaired := 0
+	errs := make([]error, 0)
+	for _, name := range g.pool.Names() {
+		n, err := g.scanDatabase(ctx, name, cursors[name])
+		repaired += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return repaired, errors.Join(errs...)
+}
+
+// scanDatabase scans heights below next of database name in batches, scanned height moves only past batches
+// which are fully repaired.
+func (g *GapRepair) scanDatabase(ctx context.Context, name string, next uint32) (int, error) {
+	database, ok := g.pool.Engine(name)
+	if !ok || next == 0 {
+		return 0, nil
+	}
+
+	from := g.startHeight
+	if scanned, ok := g.scanned[name]; ok {
+		from = scanned + 1
+	}
+
+	repaired := 0
+	for start := from; start < next; start += g.batchSize {
+		end := min(start+g.batchSize-1, next-1)
+
+		complete, err := rangeComplete(ctx, database, start, end)
+		if err != nil {
+			return repaired, err
+		}
+
+		if complete {
+			g.scanned[name] = end
+			continue
+		}
+
+		rows, err := readHeights(ctx, database, start, end)
+		if err != nil {
+			return repaired, err
+		}
+
+		for height := start; height <= end; height++ {
+			reason := gapReason(rows.blocks[height], rows.txs[height])
+			if len(reason) == 0 {
+				continue
+			}
+
+			if err := g.repair(ctx, name, height, reason); err != nil {
+				return repaired, err
+			}
+			repaired++
+		}
+
+		g.scanned[name] = end
+	}
+
+	return repaired, nil
+}
+
+func (g *GapRepair) repair(ctx context.Context, name string, height uint32, reason string) error {
+	block, txs, err := FetchBlock(ctx, g.pactus, height)
+	if err != nil {
+		return err
+	}
+
+	if err := g.pool.ReplaceBlock(ctx, block, txs, name); err != nil {
+		return err
+	}
+
+	metrics.GapsRepaired.WithLabelValues(name, reason).Inc()
+	g.logger.WarnContext(ctx, false, "Gap repaired", "db", name, "height", height, "reason", reason,
+		"transactions", len(txs))
+
+	return nil
+}
+
+// rangeComplete reports whether every height from start to end has a block and blocks declare as many
+// transactions as the range has, counted in the database so complete ranges aren't read. Transactions are
+// compared as a total of the range, so a height missing transactions is hidden by another height of the range
+// having more transactions than its block declares. Transaction hashes are unique, so that only happens when a
+// stored block declares too few transactions, which gap repair doesn't detect either way.
+func rangeComplete(ctx context.Context, database db.Database, start, end uint32) (bool, error) {
+	blocks, err := database.CountRange(ctx, schema.BlockTableName, "height", start, end)
+	if err != nil || blocks != int64(end-start+1) {
+		return false, err
+	}
+
+	declared, err := database.SumRange(ctx, schema.BlockTableName, "height", start, end, "total_transactions")
+	if err != nil {
+		return false, err
+	}
+
+	txs, err := database.CountRange(ctx, schema.TransactionsTableName, "block_height", start, end)
+	if err != nil {
+		return false, err
+	}
+
+	return declared == txs, nil
+}
+
+// gapReason returns why a height has to be fetched again, empty when block and its transactions are complete.
+func gapReason(block *schema.Block, txs []*schema.Transaction) string {
+	if block == nil {
+		return GapMissingBlock
+	}
+
+	if block.TotalTransactions != uint(len(txs)) {
+		return GapTransactionMismatch
+	}
+
+	return ""
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"testing"
+	"time"
+)
+
+func TestGapRepair_Scan(t *testing.T) {
+	ctx := context.Background()
+	node := &client.Pactus{Blockchain: &fakeBlockchain{}}
+	p := dbtest.MemoryPool(t, "gaps")
+	cfg := &schema.Config{IndexerUuid: "indexer", LastBlockHeight: 1, GapRepair: &schema.GapRepair{BatchSize: 2}}
+
+	if _, err := p.Migration(ctx, cfg.IndexerUuid, cfg.LastBlockHeight, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for height := uint32(1); height <= 5; height++ {
+		block, txs, err := FetchBlock(ctx, node, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch height {
+		case 3:
+			continue
+		case 4:
+			txs = nil
+		}
+
+		if err := p.ReplaceBlock(ctx, block, txs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// height 5 is not below cursor, it may be written by sync right now
+	if err := p.UpdateCursor(ctx, cfg.IndexerUuid, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGapRepair(cfg, node, p, dbtest.Logger(t))
+	repaired, err := g.Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repaired != 2 {
+		t.Fatalf("expected 2 repaired heights, got %d", repaired)
+	}
+
+	database, _ := p.Engine("gaps")
+	rows, err := readHeights(ctx, database, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for height := uint32(1); height <= 4; height++ {
+		if reason := gapReason(rows.blocks[height], rows.txs[height]); len(reason) != 0 {
+			t.Fatalf("height %d is not repaired: %s", height, reason)
+		}
+	}
+
+	if repaired, err := g.Scan(ctx); err != nil || repaired != 0 {
+		t.Fatalf("expected nothing to repair in second scan, got %d %v", repaired, err)
+	}
+
+	// a block lost below scanned height is found once full interval has passed
+	if err := database.DeleteRange(ctx, schema.BlockTableName, "height", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if repaired, err := g.Scan(ctx); err != nil || repaired != 0 {
+		t.Fatalf("expected lost block to wait for full scan, got %d %v", repaired, err)
+	}
+
+	now := time.Now().Add(_defaultGapFullInterval)
+	g.now = func() time.Time { return now }
+	if repaired, err := g.Scan(ctx); err != nil || repaired != 1 {
+		t.Fatalf("expected lost block repaired by full scan, got %d %v", repaired, err)
+	}
+}
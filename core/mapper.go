@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/tracing"
+	pactus "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"go.opentelemetry.io/otel/attribute"
+	"strings"
+	"time"
+)
+
+// FetchBlock fetches block of height with its transactions from the node and maps them to models.
+func FetchBlock(ctx context.Context, node *client.Pactus, height uint32) (*schema.Block, []*schema.Transaction,
+	error) {
+	fetchCtx, fetchSpan := tracing.Tracer().Start(ctx, "fetch block")
+	resp, err := node.Blockchain.GetBlock(fetchCtx, &pactus.GetBlockRequest{
+		Height:    height,
+		Verbosity: pactus.BlockVerbosity_BLOCK_TRANSACTIONS,
+	})
+	tracing.End(fetchSpan, err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, decodeSpan := tracing.Tracer().Start(ctx, "decode block")
+	block := toBlock(resp)
+	txs := toTransactions(resp)
+	decodeSpan.SetAttributes(attribute.Int("block.transactions", len(txs)))
+	decodeSpan.End()
+
+	return block, txs, nil
+}
+
+// toBlock maps rpc block response to block model.
+func toBlock(resp *pactus.GetBlockResponse) *schema.Block {
+	block := &schema.Block{
+		Height:            resp.GetHeight(),
+		Hash:              hex.EncodeToString(resp.GetHash()),
+		TotalTransactions: uint(len(resp.GetTxs())),
+		BlockTime:         resp.GetBlockTime(),
+	}
+
+	// first transaction of each block is the subsidy transaction
+	if len(resp.GetTxs()) != 0 {
+		block.BlockReward = resp.GetTxs()[0].GetValue()
+	}
+
+	if h := resp.GetHeader(); h != nil {
+		block.Version = h.GetVersion()
+		block.PrevBlockHash = hex.EncodeToString(h.GetPrevBlockHash())
+		block.StateRoot = hex.EncodeToString(h.GetStateRoot())
+		block.SortitionSeed = hex.EncodeToString(h.GetSortitionSeed())
+		block.ProposerAddress = h.GetProposerAddress()
+	}
+
+	if c := resp.GetPrevCert(); c != nil {
+		block.CertificateHash = hex.EncodeToString(c.GetHash())
+		block.Round = c.GetRound()
+		block.Committers = c.GetCommitters()
+		block.Absentees = c.GetAbsentees()
+		block.Signature = hex.EncodeToString(c.GetSignature())
+	}
+
+	return block
+}
+
+// toTransactions maps rpc block transactions to transaction models.
+func toTransactions(resp *pactus.GetBlockResponse) []*schema.Transaction {
+	txs := make([]*schema.Transaction, 0, len(resp.GetTxs()))
+	createdAt := time.Unix(int64(resp.GetBlockTime()), 0).UTC()
+
+	for _, info := range resp.GetTxs() {
+		tx := &schema.Transaction{
+			Hash:        hex.EncodeToString(info.GetId()),
+			BlockHeight: resp.GetHeight(),
+			Version:     info.GetVersion(),
+			Type:        payloadType(info.GetPayloadType()),
+			Value:       info.GetValue(),
+			Fee:         info.GetFee(),
+			Memo:        info.GetMemo(),
+			CreatedAt:   createdAt,
+		}
+
+		switch p := info.GetPayload().(type) {
+		case *pactus.TransactionInfo_Transfer:
+			tx.From = p.Transfer.GetSender()
+			tx.To = p.Transfer.GetReceiver()
+		case *pactus.TransactionInfo_Bond:
+			tx.From = p.Bond.GetSender()
+			tx.To = p.Bond.GetReceiver()
+		case *pactus.TransactionInfo_Sortition:
+			tx.From = p.Sortition.GetAddress()
+		case *pactus.TransactionInfo_Unbond:
+			tx.From = p.Unbond.GetValidator()
+		case *pactus.TransactionInfo_Withdraw:
+			tx.From = p.Withdraw.GetFrom()
+			tx.To = p.Withdraw.GetTo()
+		}
+
+		txs = append(txs, tx)
+	}
+
+	return txs
+}
+
+// payloadType converts TRANSFER_PAYLOAD to transfer.
+func payloadType(t pactus.PayloadType) string {
+	return strings.ToLower(strings.TrimSuffix(t.String(), "_PAYLOAD"))
+}
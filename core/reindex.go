@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+)
+
+const _reindexBatchSize = 100
+
+// Reindex replaces heights from..to of tables in named databases, every database when no name is given, with
+// blocks indexed again from the node. Heights are fetched in batches and every batch is deleted and stored in a
+// transaction on engines which support them, so a failed reindex leaves old rows of the heights not reached yet.
+// Cursors don't move, so the running sync and other databases aren't affected. progress is called after every
+// stored batch with its last height.
+func Reindex(ctx context.Context, node *client.Pactus, pool *db.Pool, from, to uint32, tables []string,
+	names []string, progress func(height uint32)) error {
+	for start := from; start <= to; start += _reindexBatchSize {
+		end := min(start+_reindexBatchSize-1, to)
+
+		blocks := make([]*schema.Block, 0, end-start+1)
+		txs := make([]*schema.Transaction, 0)
+		for height := start; height <= end; height++ {
+			block, blockTxs, err := FetchBlock(ctx, node, height)
+			if err != nil {
+				return err
+			}
+
+			blocks = append(blocks, block)
+			txs = append(txs, blockTxs...)
+		}
+
+		if err := pool.RebuildHeights(ctx, start, end, blocks, txs, tables, names...); err != nil {
+			return err
+		}
+		progress(end)
+
+		if end == to {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReindex(t *testing.T) {
+	ctx := context.Background()
+	node := &client.Pactus{Blockchain: &fakeBlockchain{}}
+	p := dbtest.MemoryPool(t, "target", "other")
+
+	if _, err := p.Migration(ctx, "indexer", 4, false); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := []*schema.Transaction{{Hash: "stale", BlockHeight: 2}}
+	for height := uint32(1); height <= 3; height++ {
+		if err := p.SaveBlock(ctx, &schema.Block{Height: height, Hash: "stale-" + strconv.Itoa(int(height))}, stale, db.HeightTables()); err != nil {
+			t.Fatal(err)
+		}
+		stale = nil
+	}
+
+	reindexed := make([]uint32, 0)
+	if err := Reindex(ctx, node, p, 2, 3, []string{schema.TransactionsTableName}, []string{"target"},
+		func(height uint32) { reindexed = append(reindexed, height) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reindexed) != 1 || reindexed[0] != 3 {
+		t.Fatalf("unexpected progress %v", reindexed)
+	}
+
+	for name, want := range map[string]int{"target": 2, "other": 1} {
+		database, _ := p.Engine(name)
+		txs := make([]*schema.Transaction, 0)
+		if err := database.FindRange(ctx, schema.TransactionsTableName, "block_height", 1, 3, &txs); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(txs) != want {
+			t.Fatalf("%s: expected %d transactions, got %+v", name, want, txs)
+		}
+
+		blocks := make([]*schema.Block, 0)
+		if err := database.FindRange(ctx, schema.BlockTableName, "height", 1, 3, &blocks); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, b := range blocks {
+			if !strings.HasPrefix(b.Hash, "stale") {
+				t.Fatalf("%s: blocks table is not chosen but reindexed %+v", name, b)
+			}
+		}
+	}
+
+	cursors, err := p.GetCursor(ctx, "indexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursors["target"] != 4 || cursors["other"] != 4 {
+		t.Fatalf("cursors moved %v", cursors)
+	}
+}
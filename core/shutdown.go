@@ -0,0 +1,32 @@
+package core
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"time"
+)
+
+const _defaultShutdownTimeout = 30 * time.Second
+
+// ShutdownTimeout returns how long a stopping indexer may take to drain writes and close databases.
+func ShutdownTimeout(cfg *schema.Config) time.Duration {
+	if cfg.ShutdownTimeout == 0 {
+		return _defaultShutdownTimeout
+	}
+
+	return time.Duration(cfg.ShutdownTimeout) * time.Second
+}
+
+// Drain returns a context which outlives ctx by timeout, so work started before ctx is canceled can finish
+// within the shutdown deadline.
+func Drain(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	drain, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(timeout, cancel)
+	})
+
+	return drain, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	drain, stop := Drain(ctx, 50*time.Millisecond)
+	defer stop()
+
+	cancel()
+	if drain.Err() != nil {
+		t.Fatal("drain context canceled with parent")
+	}
+
+	select {
+	case <-drain.Done():
+	case <-time.After(time.Second):
+		t.Fatal("drain context not canceled after timeout")
+	}
+}
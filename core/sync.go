@@ -1 +1,186 @@
 package core
+
+import (
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/metrics"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/tracing"
+	pactus "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"time"
+)
+
+type Sync struct {
+	pactus          *client.Pactus
+	pool            *db.Pool
+	logger          logging.Logger
+	indexerId       string
+	startHeight     uint32
+	interval        time.Duration
+	intervalC       chan time.Duration
+	shutdownTimeout time.Duration
+	lastIndexed     uint32
+}
+
+func NewSync(cfg *schema.Config, pactus *client.Pactus, pool *db.Pool, logger logging.Logger) *Sync {
+	return &Sync{
+		pactus:          pactus,
+		pool:            pool,
+		logger:          logger,
+		indexerId:       cfg.IndexerUuid,
+		startHeight:     uint32(cfg.LastBlockHeight),
+		interval:        time.Duration(cfg.SyncIntervalPerBlock) * time.Second,
+		intervalC:       make(chan time.Duration, 1),
+		shutdownTimeout: ShutdownTimeout(cfg),
+	}
+}
+
+// Start syncs blocks up to chain tip on every interval until context is canceled. A block being indexed when
+// context is canceled is still written, then cursor of every database is updated and Start returns nil.
+func (s *Sync) Start(ctx context.Context) error {
+	drain, cancel := Drain(ctx, s.shutdownTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.syncToTip(ctx, drain); err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, true, "sync failed", "err", err)
+		}
+
+		if err := s.wait(ctx, ticker); err != nil {
+			return s.shutdown(drain)
+		}
+	}
+}
+
+// shutdown writes the final cursor of every database, nothing is written when no block is indexed.
+func (s *Sync) shutdown(ctx context.Context) error {
+	if s.lastIndexed == 0 {
+		return nil
+	}
+
+	if err := s.pool.UpdateCursor(ctx, s.indexerId, s.lastIndexed); err != nil {
+		s.logger.ErrorContext(ctx, true, "final cursor update failed", "height", s.lastIndexed, "err", err)
+		return err
+	}
+	s.logger.InfoContext(ctx, false, "Final cursor updated", "height", s.lastIndexed)
+
+	return nil
+}
+
+// SetInterval changes interval between syncs of a running Start, the next sync waits for the new interval.
+func (s *Sync) SetInterval(d time.Duration) {
+	select {
+	case <-s.intervalC:
+	default:
+	}
+	s.intervalC <- d
+}
+
+// wait blocks until next tick, interval changes reset ticker without syncing.
+func (s *Sync) wait(ctx context.Context, ticker *time.Ticker) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			return nil
+		case d := <-s.intervalC:
+			ticker.Reset(d)
+			s.logger.InfoContext(ctx, false, "Sync interval changed", "interval", d.String())
+		}
+	}
+}
+
+// syncToTip fetches blocks with ctx and writes them with drain, so no block is left half written on shutdown.
+func (s *Sync) syncToTip(ctx, drain context.Context) error {
+	next, err := s.cursor(ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := s.pactus.Blockchain.GetBlockchainInfo(ctx, &pactus.GetBlockchainInfoRequest{})
+	if err != nil {
+		return err
+	}
+	metrics.SetTip(info.GetLastBlockHeight())
+
+	for height := next; height <= info.GetLastBlockHeight(); height++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := s.indexBlock(ctx, drain, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sync) indexBlock(ctx, drain context.Context, height uint32) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "index block", trace.WithAttributes(
+		attribute.Int64("block.height", int64(height))))
+	defer func() { tracing.End(span, err) }()
+	// writes keep span of block, but not cancellation of ctx
+	drain = trace.ContextWithSpan(drain, span)
+
+	block, txs, err := FetchBlock(ctx, s.pactus, height)
+	if err != nil {
+		return err
+	}
+
+	if err := s.pool.WriteBlock(drain, s.indexerId, block, txs); err != nil {
+		return err
+	}
+	s.lastIndexed = height
+	metrics.BlocksIndexed.Inc()
+	metrics.TransactionsIndexed.Add(float64(len(txs)))
+
+	s.logger.DebugContext(ctx, false, "block indexed", "height", height, "transactions", len(txs))
+
+	return nil
+}
+
+// cursor returns the lowest next height between databases and sinks, so none of them falls behind.
+func (s *Sync) cursor(ctx context.Context) (uint32, error) {
+	cursors, err := s.pool.GetCursor(ctx, s.indexerId)
+	if err != nil {
+		return 0, err
+	}
+
+	sinkCursors, err := s.pool.SinkCursors(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	heights := make([]uint32, 0, len(cursors)+len(sinkCursors))
+	for name, c := range cursors {
+		heights = append(heights, c)
+		if c > 0 {
+			metrics.SetIndexed(name, c-1, time.Time{})
+		}
+	}
+
+	for _, c := range sinkCursors {
+		if c == 0 {
+			heights = append(heights, s.startHeight)
+			continue
+		}
+		heights = append(heights, c+1)
+	}
+
+	if len(heights) == 0 {
+		return 0, errors.New("indexer cursor not found, run migrate first")
+	}
+
+	return slices.Min(heights), nil
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strconv"
+)
+
+const _verifyBatchSize = 100
+
+// Mismatch is a difference between a block of the node and the same block in a database. Want or Got is empty
+// when the row is missing on that side.
+type Mismatch struct {
+	DB     string `json:"db"`
+	Height uint32 `json:"height"`
+	Field  string `json:"field"`
+	Want   string `json:"want"`
+	Got    string `json:"got"`
+}
+
+// VerifyFunc gets block and transactions of the node at a height which differs in some databases.
+type VerifyFunc func(ctx context.Context, block *schema.Block, txs []*schema.Transaction, mismatches []Mismatch) error
+
+// Verify compares blocks from..to of every database with the node field by field: a missing block, hash,
+// proposer, transaction count and transaction hashes. Databases are read in batches and every height is
+// fetched once from the node, fn is called for every height which differs.
+func Verify(ctx context.Context, node *client.Pactus, pool *db.Pool, from, to uint32, fn VerifyFunc) error {
+	names := pool.Names()
+
+	for start := from; start <= to; {
+		end := to
+		if to-start >= _verifyBatchSize {
+			end = start + _verifyBatchSize - 1
+		}
+
+		indexed := make(map[string]*heightRows, len(names))
+		for _, name := range names {
+			database, ok := pool.Engine(name)
+			if !ok {
+				continue
+			}
+
+			rows, err := readHeights(ctx, database, start, end)
+			if err != nil {
+				return err
+			}
+			indexed[name] = rows
+		}
+
+		for height := start; height <= end; height++ {
+			block, txs, err := FetchBlock(ctx, node, height)
+			if err != nil {
+				return err
+			}
+
+			mismatches := make([]Mismatch, 0)
+			for _, name := range names {
+				rows, ok := indexed[name]
+				if !ok {
+					continue
+				}
+				mismatches = append(mismatches,
+					compareBlock(name, block, txs, rows.blocks[height], rows.txs[height])...)
+			}
+
+			if len(mismatches) != 0 {
+				if err := fn(ctx, block, txs, mismatches); err != nil {
+					return err
+				}
+			}
+
+			if height == end {
+				break
+			}
+		}
+
+		if end == to {
+			break
+		}
+		start = end + 1
+	}
+
+	return nil
+}
+
+// heightRows are blocks and transactions of a database by height.
+type heightRows struct {
+	blocks map[uint32]*schema.Block
+	txs    map[uint32][]*schema.Transaction
+}
+
+func readHeights(ctx context.Context, database db.Database, from, to uint32) (*heightRows, error) {
+	blocks := make([]*schema.Block, 0)
+	if err := database.FindRange(ctx, schema.BlockTableName, "height", from, to, &blocks); err != nil {
+		return nil, err
+	}
+
+	txs := make([]*schema.Transaction, 0)
+	if err := database.FindRange(ctx, schema.TransactionsTableName, "block_height", from, to, &txs); err != nil {
+		return nil, err
+	}
+
+	rows := &heightRows{
+		blocks: make(map[uint32]*schema.Block, len(blocks)),
+		txs:    make(map[uint32][]*schema.Transaction, len(blocks)),
+	}
+	for _, b := range blocks {
+		rows.blocks[b.Height] = b
+	}
+	for _, tx := range txs {
+		rows.txs[tx.BlockHeight] = append(rows.txs[tx.BlockHeight], tx)
+	}
+
+	return rows, nil
+}
+
+// compareBlock returns differences of got block and transactions of database name with want of the node.
+func compareBlock(name string, want *schema.Block, wantTxs []*schema.Transaction, got *schema.Block,
+	gotTxs []*schema.Transaction) []Mismatch {
+	mismatches := make([]Mismatch, 0)
+	add := func(field, wantVal, gotVal string) {
+		mismatches = append(mismatches, Mismatch{DB: name, Height: want.Height, Field: field, Want: wantVal,
+			Got: gotVal})
+	}
+
+	if got == nil {
+		add("block", want.Hash, "")
+	} else {
+		if got.Hash != want.Hash {
+			add("hash", want.Hash, got.Hash)
+		}
+		if got.ProposerAddress != want.ProposerAddress {
+			add("proposer_address", want.ProposerAddress, got.ProposerAddress)
+		}
+		if got.TotalTransactions != uint(len(wantTxs)) {
+			add("total_transactions", strconv.Itoa(len(wantTxs)), strconv.FormatUint(uint64(got.TotalTransactions), 10))
+		}
+	}
+
+	gotHashes := make(map[string]bool, len(gotTxs))
+	for _, tx := range gotTxs {
+		gotHashes[tx.Hash] = true
+	}
+
+	wantHashes := make(map[string]bool, len(wantTxs))
+	for _, tx := range wantTxs {
+		wantHashes[tx.Hash] = true
+		if !gotHashes[tx.Hash] {
+			add("transaction", tx.Hash, "")
+		}
+	}
+
+	for _, tx := range gotTxs {
+		if !wantHashes[tx.Hash] {
+			add("transaction", "", tx.Hash)
+		}
+	}
+
+	return mismatches
+}
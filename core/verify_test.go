@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	pactus "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"google.golang.org/grpc"
+	"testing"
+)
+
+// fakeBlockchain serves blocks with one transaction per height.
+type fakeBlockchain struct {
+	pactus.BlockchainClient
+}
+
+func (f *fakeBlockchain) GetBlock(_ context.Context, req *pactus.GetBlockRequest,
+	_ ...grpc.CallOption) (*pactus.GetBlockResponse, error) {
+	return &pactus.GetBlockResponse{
+		Height: req.GetHeight(),
+		Hash:   []byte{byte(req.GetHeight())},
+		Header: &pactus.BlockHeaderInfo{ProposerAddress: "proposer"},
+		Txs:    []*pactus.TransactionInfo{{Id: []byte{0xff, byte(req.GetHeight())}}},
+	}, nil
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	node := &client.Pactus{Blockchain: &fakeBlockchain{}}
+	p := dbtest.MemoryPool(t, "good", "bad")
+
+	for height := uint32(1); height <= 3; height++ {
+		block, txs, err := FetchBlock(ctx, node, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{"good", "bad"}
+		if height == 2 {
+			names = names[:1]
+		}
+		if height == 3 {
+			if err := p.ReplaceBlock(ctx, block, txs, "good"); err != nil {
+				t.Fatal(err)
+			}
+			block.Hash = "forked"
+			txs = nil
+			names = names[1:]
+		}
+
+		if err := p.ReplaceBlock(ctx, block, txs, names...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make([]Mismatch, 0)
+	err := Verify(ctx, node, p, 1, 3, func(ctx context.Context, block *schema.Block, txs []*schema.Transaction,
+		mismatches []Mismatch) error {
+		got = append(got, mismatches...)
+
+		return p.ReplaceBlock(ctx, block, txs, "bad")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx2, tx3 := hex.EncodeToString([]byte{0xff, 2}), hex.EncodeToString([]byte{0xff, 3})
+	want := []Mismatch{
+		{DB: "bad", Height: 2, Field: "block", Want: "02"},
+		{DB: "bad", Height: 2, Field: "transaction", Want: tx2},
+		{DB: "bad", Height: 3, Field: "hash", Want: "03", Got: "forked"},
+		{DB: "bad", Height: 3, Field: "transaction", Want: tx3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("mismatch %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	err = Verify(ctx, node, p, 1, 3, func(_ context.Context, _ *schema.Block, _ []*schema.Transaction,
+		mismatches []Mismatch) error {
+		t.Fatalf("unexpected mismatches after repair %v", mismatches)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,552 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _versionColumn is the ReplacingMergeTree version, the row inserted last wins when parts merge.
+const _versionColumn = "inserted_at"
+
+// clickHouseTables are created by Migrate, every table is a ReplacingMergeTree, so inserting a row with the
+// same sorting key again replaces the old one. Reads use FINAL to see replaced rows only.
+var clickHouseTables = []string{
+	"CREATE TABLE IF NOT EXISTS `" + schema.BlockTableName + "` (" +
+		"`height` UInt32, `hash` String, `total_transactions` UInt64, `block_time` UInt32, `block_reward` Int64, " +
+		"`version` Int32, `prev_block_hash` String, `state_root` String, `sortition_seed` String, " +
+		"`proposer_address` LowCardinality(String), `certificate_hash` String, `round` Int32, " +
+		"`committers` Array(Int32), `absentees` Array(Int32), `signature` String, " +
+		"`" + _versionColumn + "` DateTime64(9) DEFAULT now64(9)" +
+		") ENGINE = ReplacingMergeTree(" + _versionColumn + ") ORDER BY `height`",
+
+	"CREATE TABLE IF NOT EXISTS `" + schema.TransactionsTableName + "` (" +
+		"`hash` String, `block_height` UInt32, `version` Int32, `type` LowCardinality(String), `from` String, " +
+		"`to` String, `value` Int64, `fee` Int64, `memo` String, `created_at` DateTime, " +
+		"`" + _versionColumn + "` DateTime64(9) DEFAULT now64(9)" +
+		") ENGINE = ReplacingMergeTree(" + _versionColumn + ") PARTITION BY toYYYYMM(`created_at`) " +
+		"ORDER BY (`block_height`, `hash`)",
+
+	"CREATE TABLE IF NOT EXISTS `" + schema.IndexerTableName + "` (" +
+		"`index_id` String, `last_block_height` Int64, `indexed_at` DateTime64(3), " +
+		"`" + _versionColumn + "` DateTime64(9) DEFAULT now64(9)" +
+		") ENGINE = ReplacingMergeTree(" + _versionColumn + ") ORDER BY `index_id`",
+
+	"CREATE TABLE IF NOT EXISTS `" + schema.WebhookOutboxTableName + "` (" +
+		"`event_id` String, `webhook` String, `event` LowCardinality(String), `block_height` UInt32, " +
+		"`payload` String, `status` LowCardinality(String), `attempts` Int64, `next_attempt_at` DateTime64(3), " +
+		"`last_error` String, `created_at` DateTime64(3), " +
+		"`" + _versionColumn + "` DateTime64(9) DEFAULT now64(9)" +
+		") ENGINE = ReplacingMergeTree(" + _versionColumn + ") ORDER BY `event_id`",
+}
+
+type ClickHouse struct {
+	conn     driver.Conn
+	engine   schema.DatabaseEngine
+	dbType   schema.DatabaseType
+	uri      string
+	name     string
+	timeouts timeouts
+	columns  sync.Map // table name to []driver.ColumnType
+}
+
+// clickhouseOptions parses uri and turns on async inserts unless uri sets them. Every block is a small insert,
+// the server buffers them into larger parts instead of creating a part per block, which ends in too many parts.
+// Inserts wait for the buffer to be written, so cursors only move past stored rows.
+func clickhouseOptions(uri string) (*clickhouse.Options, error) {
+	opts, err := clickhouse.ParseDSN(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Settings == nil {
+		opts.Settings = clickhouse.Settings{}
+	}
+
+	for name, val := range map[string]any{"async_insert": 1, "wait_for_async_insert": 1} {
+		if _, ok := opts.Settings[name]; !ok {
+			opts.Settings[name] = val
+		}
+	}
+
+	return opts, nil
+}
+
+func NewClickHouse(ctx context.Context, dbCfg *schema.DB) (Database, error) {
+	opts, err := clickhouseOptions(dbCfg.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	t := newTimeouts(dbCfg)
+	opts.DialTimeout = t.connect
+	if dbCfg.MaxOpenConns != 0 {
+		opts.MaxOpenConns = dbCfg.MaxOpenConns
+	}
+	if dbCfg.MaxIdleConns != 0 {
+		opts.MaxIdleConns = dbCfg.MaxIdleConns
+	}
+	if dbCfg.ConnMaxLifetime != 0 {
+		opts.ConnMaxLifetime = time.Duration(dbCfg.ConnMaxLifetime) * time.Second
+	}
+
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pingCtx, cancel := t.connectCtx(ctx)
+	defer cancel()
+	if err := conn.Ping(pingCtx); err != nil {
+		return nil, errors.Join(errors.New("clickhouse: can't verify client connection "), err, conn.Close())
+	}
+
+	return &ClickHouse{
+		conn:     conn,
+		engine:   dbCfg.Engine,
+		dbType:   dbCfg.Type,
+		uri:      dbCfg.URI,
+		name:     dbCfg.Name,
+		timeouts: t,
+	}, nil
+}
+
+func (c *ClickHouse) Close() error {
+	return c.conn.Close()
+}
+
+func (c *ClickHouse) Ping(ctx context.Context) error {
+	ctx, cancel := c.timeouts.connectCtx(ctx)
+	defer cancel()
+
+	return c.conn.Ping(ctx)
+}
+
+func (c *ClickHouse) Migrate(ctx context.Context) error {
+	for _, ddl := range clickHouseTables {
+		if err := c.conn.Exec(ctx, ddl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ClickHouse) Name() string {
+	return c.name
+}
+
+func (c *ClickHouse) Type() string {
+	return c.dbType.String()
+}
+
+func (c *ClickHouse) Engine() string {
+	return c.engine.String()
+}
+
+func (c *ClickHouse) FindOne(ctx context.Context, tableOrCollectionName string, key string, val any,
+	resultPtr any) error {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	rows, err := c.conn.Query(ctx, selectQuery(tableOrCollectionName)+
+		" WHERE "+quote(key)+" = ? LIMIT 1", val)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	return scanStruct(rows, reflect.ValueOf(resultPtr).Elem())
+}
+
+func (c *ClickHouse) FindMany(ctx context.Context, tableOrCollectionName string, key string, val any,
+	resultSlicePtr any) error {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return c.selectInto(ctx, resultSlicePtr, selectQuery(tableOrCollectionName)+
+		" WHERE "+quote(key)+" = ?", val)
+}
+
+// FindRange finds rows which key is between from and to inclusive, ordered by key.
+func (c *ClickHouse) FindRange(ctx context.Context, tableOrCollectionName string, key string, from, to any,
+	resultSlicePtr any) error {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return c.selectInto(ctx, resultSlicePtr, selectQuery(tableOrCollectionName)+
+		" WHERE "+quote(key)+" BETWEEN ? AND ? ORDER BY "+quote(key), from, to)
+}
+
+// FindPage finds at most limit rows matching key which orderKey is greater than after, ordered by orderKey.
+func (c *ClickHouse) FindPage(ctx context.Context, tableOrCollectionName string, key string, val any,
+	orderKey string, after any, limit int, resultSlicePtr any) error {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return c.selectInto(ctx, resultSlicePtr, selectQuery(tableOrCollectionName)+
+		" WHERE "+quote(key)+" = ? AND "+quote(orderKey)+" > ? ORDER BY "+quote(orderKey)+" LIMIT ?",
+		val, after, limit)
+}
+
+// Count returns number of rows in table, replaced rows are not counted.
+func (c *ClickHouse) Count(ctx context.Context, tableOrCollectionName string) (int64, error) {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	var n uint64
+	if err := c.conn.QueryRow(ctx, "SELECT count() FROM "+quote(tableOrCollectionName)+" FINAL").
+		Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return int64(n), nil
+}
+
+// CountRange returns number of rows which key is between from and to inclusive, replaced rows are not counted.
+func (c *ClickHouse) CountRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) (int64,
+	error) {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	var n uint64
+	if err := c.conn.QueryRow(ctx, "SELECT count() FROM "+quote(tableOrCollectionName)+" FINAL WHERE "+
+		quote(key)+" BETWEEN ? AND ?", from, to).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return int64(n), nil
+}
+
+// SumRange returns sum of field of rows which key is between from and to inclusive, replaced rows are not
+// counted.
+func (c *ClickHouse) SumRange(ctx context.Context, tableOrCollectionName string, key string, from, to any,
+	field string) (int64, error) {
+	ctx, cancel := c.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	var sum int64
+	if err := c.conn.QueryRow(ctx, "SELECT toInt64(sum("+quote(field)+")) FROM "+quote(tableOrCollectionName)+
+		" FINAL WHERE "+quote(key)+" BETWEEN ? AND ?", from, to).Scan(&sum); err != nil {
+		return 0, err
+	}
+
+	return sum, nil
+}
+
+// DeleteRange deletes rows which key is between from and to inclusive. It runs a mutation and waits for it on
+// every replica, so rows inserted afterwards aren't deleted by it.
+func (c *ClickHouse) DeleteRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) error {
+	ctx, cancel := c.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 2}))
+
+	return c.conn.Exec(ctx, "ALTER TABLE "+quote(tableOrCollectionName)+" DELETE WHERE "+quote(key)+
+		" BETWEEN ? AND ?", from, to)
+}
+
+func (c *ClickHouse) InsertOne(ctx context.Context, tableOrCollectionName string, dataPtr any) error {
+	return c.InsertMany(ctx, tableOrCollectionName, []any{dataPtr})
+}
+
+// InsertMany sends all rows in a single columnar batch.
+func (c *ClickHouse) InsertMany(ctx context.Context, tableOrCollectionName string, dataPtr []any) error {
+	ctx, cancel := c.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	columns, err := c.tableColumns(ctx, tableOrCollectionName)
+	if err != nil {
+		return err
+	}
+
+	batch, err := c.conn.PrepareBatch(ctx, insertQuery(tableOrCollectionName, columns))
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dataPtr {
+		values, err := structValues(reflect.Indirect(reflect.ValueOf(d)), columns)
+		if err != nil {
+			return errors.Join(err, batch.Abort())
+		}
+
+		if err := batch.Append(values...); err != nil {
+			return errors.Join(err, batch.Abort())
+		}
+	}
+
+	return batch.Send()
+}
+
+// UpsertMany inserts rows like InsertMany, ReplacingMergeTree replaces rows with the same sorting key, which
+// is key of every table.
+func (c *ClickHouse) UpsertMany(ctx context.Context, tableOrCollectionName string, _ string, dataPtr []any) error {
+	return c.InsertMany(ctx, tableOrCollectionName, dataPtr)
+}
+
+// InsertNew inserts rows which key isn't stored yet, ClickHouse has no conflict clause so stored keys are looked
+// up first. Rows aren't locked meanwhile, so it relies on a single writer like the indexer.
+func (c *ClickHouse) InsertNew(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	rows := make([]any, 0, len(dataPtr))
+	for _, d := range dataPtr {
+		v := reflect.Indirect(reflect.ValueOf(d))
+		idx, ok := chFields(v.Type())[key]
+		if !ok {
+			return fmt.Errorf("clickhouse: unknown field %s in %T", key, d)
+		}
+
+		val := v.Field(idx).Interface()
+		n, err := c.CountRange(ctx, tableOrCollectionName, key, val, val)
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			rows = append(rows, d)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return c.InsertMany(ctx, tableOrCollectionName, rows)
+}
+
+func (c *ClickHouse) UpdateOne(ctx context.Context, tableOrCollectionName string, key string, val any,
+	updKey string, updVal any) error {
+	return c.UpdateFields(ctx, tableOrCollectionName, key, val, map[string]any{updKey: updVal})
+}
+
+// UpdateFields inserts a new version of matching rows instead of running a mutation, the old version is
+// dropped by ReplacingMergeTree.
+func (c *ClickHouse) UpdateFields(ctx context.Context, tableOrCollectionName string, key string, val any,
+	fields map[string]any) error {
+	ctx, cancel := c.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	columns, err := c.tableColumns(ctx, tableOrCollectionName)
+	if err != nil {
+		return err
+	}
+
+	// rows are read and closed before the batch takes a connection, so a pool of one connection doesn't block
+	updated, err := c.updatedRows(ctx, tableOrCollectionName, columns, key, val, fields)
+	if err != nil || len(updated) == 0 {
+		return err
+	}
+
+	batch, err := c.conn.PrepareBatch(ctx, insertQuery(tableOrCollectionName, columns))
+	if err != nil {
+		return err
+	}
+
+	for _, values := range updated {
+		if err := batch.Append(values...); err != nil {
+			return errors.Join(err, batch.Abort())
+		}
+	}
+
+	return batch.Send()
+}
+
+// updatedRows reads rows matching key with values of columns, fields replace values of their columns.
+func (c *ClickHouse) updatedRows(ctx context.Context, table string, columns []driver.ColumnType, key string,
+	val any, fields map[string]any) ([][]any, error) {
+	rows, err := c.conn.Query(ctx, selectQuery(table)+" WHERE "+quote(key)+" = ?", val)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updated := make([][]any, 0)
+	for rows.Next() {
+		values := make([]any, len(columns))
+		for i, col := range columns {
+			values[i] = reflect.New(col.ScanType()).Interface()
+		}
+
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+
+		for i, col := range columns {
+			v := reflect.ValueOf(values[i]).Elem()
+			if upd, ok := fields[col.Name()]; ok {
+				if v, err = convert(reflect.ValueOf(upd), col.ScanType()); err != nil {
+					return nil, err
+				}
+			}
+			values[i] = v.Interface()
+		}
+		updated = append(updated, values)
+	}
+
+	return updated, rows.Err()
+}
+
+func (c *ClickHouse) selectInto(ctx context.Context, resultSlicePtr any, query string, args ...any) error {
+	rows, err := c.conn.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	slice := reflect.ValueOf(resultSlicePtr).Elem()
+	elemType := slice.Type().Elem()
+
+	for rows.Next() {
+		elem := reflect.New(elemType).Elem()
+		dest := elem
+		if elemType.Kind() == reflect.Pointer {
+			elem.Set(reflect.New(elemType.Elem()))
+			dest = elem.Elem()
+		}
+
+		if err := scanStruct(rows, dest); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+
+	return rows.Err()
+}
+
+// tableColumns returns writable columns of table, version column is filled by clickhouse.
+func (c *ClickHouse) tableColumns(ctx context.Context, table string) ([]driver.ColumnType, error) {
+	if cols, ok := c.columns.Load(table); ok {
+		return cols.([]driver.ColumnType), nil
+	}
+
+	rows, err := c.conn.Query(ctx, selectQuery(table)+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := rows.ColumnTypes()
+	c.columns.Store(table, cols)
+
+	return cols, nil
+}
+
+func selectQuery(table string) string {
+	return "SELECT * EXCEPT (" + quote(_versionColumn) + ") FROM " + quote(table) + " FINAL"
+}
+
+func insertQuery(table string, columns []driver.ColumnType) string {
+	names := make([]string, 0, len(columns))
+	for _, col := range columns {
+		names = append(names, quote(col.Name()))
+	}
+
+	return "INSERT INTO " + quote(table) + " (" + strings.Join(names, ", ") + ")"
+}
+
+func quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "\\`") + "`"
+}
+
+// chFields maps ch tag of struct fields to field index.
+func chFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("ch"), ",")
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+		fields[name] = i
+	}
+
+	return fields
+}
+
+// scanStruct scans current row into struct, clickhouse driver doesn't convert between
+// integer sizes, so values are scanned as column type and converted to field type.
+func scanStruct(rows driver.Rows, dest reflect.Value) error {
+	types := rows.ColumnTypes()
+	values := make([]any, len(types))
+	for i, col := range types {
+		values[i] = reflect.New(col.ScanType()).Interface()
+	}
+
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+
+	fields := chFields(dest.Type())
+	for i, col := range types {
+		idx, ok := fields[col.Name()]
+		if !ok {
+			continue
+		}
+
+		field := dest.Field(idx)
+		v, err := convert(reflect.ValueOf(values[i]).Elem(), field.Type())
+		if err != nil {
+			return fmt.Errorf("clickhouse: column %s: %w", col.Name(), err)
+		}
+		field.Set(v)
+	}
+
+	return nil
+}
+
+// structValues returns struct field values in columns order, converted to column types.
+func structValues(v reflect.Value, columns []driver.ColumnType) ([]any, error) {
+	fields := chFields(v.Type())
+	values := make([]any, len(columns))
+
+	for i, col := range columns {
+		idx, ok := fields[col.Name()]
+		if !ok {
+			values[i] = reflect.Zero(col.ScanType()).Interface()
+			continue
+		}
+
+		cv, err := convert(v.Field(idx), col.ScanType())
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse: column %s: %w", col.Name(), err)
+		}
+		values[i] = cv.Interface()
+	}
+
+	return values, nil
+}
+
+func convert(v reflect.Value, to reflect.Type) (reflect.Value, error) {
+	switch {
+	case v.Type() == to:
+		return v, nil
+	case v.Type().ConvertibleTo(to):
+		return v.Convert(to), nil
+	case v.Kind() == reflect.Slice && to.Kind() == reflect.Slice:
+		out := reflect.MakeSlice(to, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := convert(v.Index(i), to.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(e)
+		}
+		return out, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("can't convert %s to %s", v.Type(), to)
+}
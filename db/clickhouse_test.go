@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testColumn struct {
+	name     string
+	scanType reflect.Type
+}
+
+func (c testColumn) Name() string             { return c.name }
+func (c testColumn) Nullable() bool           { return false }
+func (c testColumn) ScanType() reflect.Type   { return c.scanType }
+func (c testColumn) DatabaseTypeName() string { return c.scanType.String() }
+
+func TestClickHouse_StructValues(t *testing.T) {
+	columns := []driver.ColumnType{
+		testColumn{"height", reflect.TypeOf(uint32(0))},
+		testColumn{"total_transactions", reflect.TypeOf(uint64(0))},
+		testColumn{"committers", reflect.TypeOf([]int32{})},
+		testColumn{"not_in_struct", reflect.TypeOf("")},
+	}
+
+	block := schema.Block{Height: 7, TotalTransactions: 3, Committers: []int32{1, 2}}
+	values, err := structValues(reflect.ValueOf(block), columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []any{uint32(7), uint64(3), []int32{1, 2}, ""}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+
+	indexer := schema.Indexer{}
+	if _, err := structValues(reflect.ValueOf(indexer),
+		[]driver.ColumnType{testColumn{"index_id", reflect.TypeOf(time.Time{})}}); err == nil {
+		t.Fatal("expected conversion error")
+	}
+}
+
+// TestClickHouse_Reindex runs against a clickhouse server, e.g.
+// CLICKHOUSE_URI=clickhouse://default:@localhost:9000/default go test ./db
+func TestClickHouse_Reindex(t *testing.T) {
+	uri := os.Getenv("CLICKHOUSE_URI")
+	if len(uri) == 0 {
+		t.Skip("CLICKHOUSE_URI is not set")
+	}
+
+	ctx := context.Background()
+	ch, err := NewClickHouse(ctx, &schema.DB{Name: "clickhouse", Type: schema.SQL, Engine: schema.CLICKHOUSE, URI: uri})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Close()
+
+	for _, table := range []string{schema.BlockTableName, schema.TransactionsTableName, schema.IndexerTableName,
+		schema.WebhookOutboxTableName} {
+		if err := ch.(*ClickHouse).conn.Exec(ctx, "DROP TABLE IF EXISTS "+quote(table)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(ch)
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// writing the same heights twice must not duplicate rows
+	for i := 0; i < 2; i++ {
+		for h := uint32(1); h <= 3; h++ {
+			block := &schema.Block{Height: h, Hash: string(rune('a' + h)), Committers: []int32{1, 2}}
+			txs := []*schema.Transaction{
+				{Hash: string(rune('k' + h)), BlockHeight: h, Type: "transfer", Value: 10, CreatedAt: time.Now()},
+			}
+
+			if err := p.WriteBlock(ctx, testIndexerId, block, txs); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	blocks := make([]schema.Block, 0)
+	if err := ch.FindRange(ctx, schema.BlockTableName, "height", 1, 3, &blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 3 || blocks[2].Height != 3 || len(blocks[2].Committers) != 2 {
+		t.Fatalf("expected 3 blocks, got %v", blocks)
+	}
+
+	txs := make([]*schema.Transaction, 0)
+	if err := ch.FindMany(ctx, schema.TransactionsTableName, "block_height", 2, &txs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txs) != 1 || txs[0].Value != 10 {
+		t.Fatalf("expected 1 transaction, got %v", txs)
+	}
+
+	var indexer schema.Indexer
+	if err := ch.FindOne(ctx, schema.IndexerTableName, "index_id", testIndexerId, &indexer); err != nil {
+		t.Fatal(err)
+	}
+
+	if indexer.LastBlockHeight != 4 {
+		t.Fatalf("expected cursor 4, got %d", indexer.LastBlockHeight)
+	}
+}
+
+func TestClickHouse_AsyncInsertOptions(t *testing.T) {
+	opts, err := clickhouseOptions("clickhouse://default:@localhost:9000/default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.Settings["async_insert"] != 1 || opts.Settings["wait_for_async_insert"] != 1 {
+		t.Fatalf("expected async inserts waiting for flush, got %v", opts.Settings)
+	}
+
+	// settings of uri win
+	opts, err = clickhouseOptions("clickhouse://default:@localhost:9000/default?async_insert=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.Settings["async_insert"] == 1 {
+		t.Fatalf("expected async_insert of uri kept, got %v", opts.Settings)
+	}
+}
+
+func TestClickHouse_UpdateFieldsSingleConn(t *testing.T) {
+	uri := os.Getenv("CLICKHOUSE_URI")
+	if len(uri) == 0 {
+		t.Skip("CLICKHOUSE_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ch, err := NewClickHouse(ctx, &schema.DB{Name: "clickhouse", Type: schema.SQL, Engine: schema.CLICKHOUSE,
+		URI: uri, MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Close()
+
+	if err := ch.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ch.DeleteRange(ctx, schema.WebhookOutboxTableName, "event_id", "single", "single"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ch.InsertOne(ctx, schema.WebhookOutboxTableName, &schema.WebhookOutbox{EventId: "single",
+		Status: schema.OutboxPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	// reading matching rows must not hold the only connection while the batch is prepared
+	if err := ch.UpdateFields(ctx, schema.WebhookOutboxTableName, "event_id", "single",
+		map[string]any{"status": schema.OutboxDelivered}); err != nil {
+		t.Fatal(err)
+	}
+
+	var row schema.WebhookOutbox
+	if err := ch.FindOne(ctx, schema.WebhookOutboxTableName, "event_id", "single", &row); err != nil ||
+		row.Status != schema.OutboxDelivered {
+		t.Fatalf("expected delivered row, got %+v, %v", row, err)
+	}
+}
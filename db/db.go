@@ -2,10 +2,18 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/metrics"
 	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+	"slices"
+	"sync"
+	"time"
 )
 
 type Database interface {
@@ -14,82 +22,293 @@ type Database interface {
 	Type() string
 	Engine() string
 	Close() error
-	Migrate(ctx context.Context, indexerUUid string, lastBlockHeight int) error
+	// Ping checks connection to backend within connect timeout.
+	Ping(ctx context.Context) error
+	// Migrate brings schema to latest version, it's safe to run on a migrated database.
+	Migrate(ctx context.Context) error
+	// Count returns number of rows in table.
+	Count(ctx context.Context, tableOrCollectionName string) (int64, error)
+	// CountRange returns number of rows which key is between from and to inclusive.
+	CountRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) (int64, error)
+	// SumRange returns sum of field of rows which key is between from and to inclusive.
+	SumRange(ctx context.Context, tableOrCollectionName string, key string, from, to any, field string) (int64,
+		error)
+	// DeleteRange deletes rows which key is between from and to inclusive.
+	DeleteRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) error
+	// UpsertMany stores rows, a stored row with the same value of unique key is replaced.
+	UpsertMany(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error
+	// InsertNew stores rows which value of unique key isn't stored yet, stored rows are kept as they are.
+	InsertNew(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error
+}
+
+// Transactor is implemented by databases which can commit several writes atomically.
+type Transactor interface {
+	// Transaction runs fn with a database bound to a transaction, which is committed when fn returns nil and
+	// rolled back otherwise.
+	Transaction(ctx context.Context, fn func(ctx context.Context, tx Database) error) error
 }
 
 type Executor interface {
 	FindOne(ctx context.Context, tableOrCollectionName string, key string, val any, resultPtr any) error
+	FindMany(ctx context.Context, tableOrCollectionName string, key string, val any, resultSlicePtr any) error
+	FindRange(ctx context.Context, tableOrCollectionName string, key string, from, to any, resultSlicePtr any) error
+	// FindPage finds at most limit rows matching key which orderKey is greater than after, ordered by orderKey.
+	FindPage(ctx context.Context, tableOrCollectionName string, key string, val any, orderKey string, after any,
+		limit int, resultSlicePtr any) error
 	InsertOne(ctx context.Context, tableOrCollectionName string, dataPtr any) error
 	InsertMany(ctx context.Context, tableOrCollectionName string, dataPtr []any) error
 	UpdateOne(ctx context.Context, tableOrCollectionName string, key string, val any, updKey string, updVal any) error
+	UpdateFields(ctx context.Context, tableOrCollectionName string, key string, val any, fields map[string]any) error
+}
+
+// Outbox builds rows which are stored with every block in a single database, like webhook deliveries.
+type Outbox interface {
+	// OutboxRows returns rows of block, all of the same model.
+	OutboxRows(block *schema.Block, txs []*schema.Transaction) ([]any, error)
 }
 
 type Pool struct {
-	items   []Database
-	logging logging.Logger
+	mu       sync.RWMutex
+	items    []Database
+	cursors  map[string]uint32 // next height of databases by name, as last read or written by pool
+	sinks    []Sink
+	outbox   Outbox
+	outboxDB string
+	logging  logging.Logger
 }
 
 func NewPool(logging logging.Logger) *Pool {
 	return &Pool{
 		items:   make([]Database, 0),
+		cursors: make(map[string]uint32),
+		sinks:   make([]Sink, 0),
 		logging: logging,
 	}
 }
 
 func (p *Pool) RegisterEngine(db Database) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.items = append(p.items, db)
 }
 
+func (p *Pool) RegisterSink(sink Sink) {
+	p.sinks = append(p.sinks, sink)
+}
+
+// SetOutbox makes WriteBlock store rows of outbox with every block in database name. They're written with the
+// block, in the same transaction on engines which support them, so no row is lost once the cursor moves.
+func (p *Pool) SetOutbox(name string, outbox Outbox) error {
+	if _, ok := p.Engine(name); !ok {
+		return fmt.Errorf("outbox database %s is not registered", name)
+	}
+
+	p.outbox = outbox
+	p.outboxDB = name
+
+	return nil
+}
+
+// Engine returns registered database by name.
+func (p *Pool) Engine(name string) (Database, bool) {
+	for _, item := range p.engines() {
+		if item.Name() == name {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+// engines returns a snapshot of registered databases, so registering doesn't race with running operations.
+func (p *Pool) engines() []Database {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return slices.Clone(p.items)
+}
+
+// Close closes every database and sink, a failing one doesn't stop the others from closing.
 func (p *Pool) Close() error {
-	for _, item := range p.items {
+	errs := make([]error, 0)
+
+	for _, item := range p.engines() {
 		if err := item.Close(); err != nil {
-			return err
+			errs = append(errs, newErr(item.Name(), item.Engine(), item.Type(), err.Error()))
 		}
 	}
-	return nil
+
+	for _, sink := range p.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, newSinkErr(sink.Name(), err.Error()))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func (p *Pool) Migration(ctx context.Context, indexerUUid string, lastBlockHeight int) error {
+// Migration migrates every database to latest schema and registers the indexer. An existing indexer keeps its
+// cursor unless resetCursor is set.
+func (p *Pool) Migration(ctx context.Context, indexerUUid string, lastBlockHeight int,
+	resetCursor bool) ([]*MigrationReport, error) {
+	items := p.engines()
+	reports := make([]*MigrationReport, len(items))
 	gp, gpCtx := errgroup.WithContext(ctx)
 
-	for _, item := range p.items {
+	for i, item := range items {
 		gp.Go(func() error {
 			p.logging.Info(false, fmt.Sprintf("Start migrate database %s", item.Name()))
-			return item.Migrate(gpCtx, indexerUUid, lastBlockHeight)
+
+			report, err := migrateDatabase(gpCtx, item, indexerUUid, lastBlockHeight, resetCursor)
+			if err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+			reports[i] = report
+			p.setCursor(item.Name(), uint32(report.LastBlockHeight))
+
+			return nil
+		})
+	}
+
+	if err := gp.Wait(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// MigrateTo moves every database with versioned migrations to version, other engines are skipped.
+func (p *Pool) MigrateTo(ctx context.Context, version uint) error {
+	return p.eachMigrator(ctx, func(ctx context.Context, m Migrator) error {
+		return m.MigrateTo(ctx, version)
+	})
+}
+
+// MigrateDown reverts last n applied migrations of every database with versioned migrations.
+func (p *Pool) MigrateDown(ctx context.Context, n uint) error {
+	return p.eachMigrator(ctx, func(ctx context.Context, m Migrator) error {
+		status, err := m.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		return m.MigrateTo(ctx, downTarget(status, n))
+	})
+}
+
+// MigrationStatus returns migrations state by database name.
+func (p *Pool) MigrationStatus(ctx context.Context) (map[string][]MigrationStatus, error) {
+	items := p.engines()
+	status := make(map[string][]MigrationStatus, len(items))
+
+	for _, item := range items {
+		m, ok := item.(Migrator)
+		if !ok {
+			continue
+		}
+
+		st, err := m.MigrationStatus(ctx)
+		if err != nil {
+			return nil, newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+		}
+		status[item.Name()] = st
+	}
+
+	return status, nil
+}
+
+func (p *Pool) eachMigrator(ctx context.Context, fn func(ctx context.Context, m Migrator) error) error {
+	gp, gpCtx := errgroup.WithContext(ctx)
+
+	for _, item := range p.engines() {
+		m, ok := item.(Migrator)
+		if !ok {
+			p.logging.Warn(false, fmt.Sprintf("Database %s doesn't support versioned migrations, skipped",
+				item.Name()))
+			continue
+		}
+
+		gp.Go(func() error {
+			if err := fn(gpCtx, m); err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+
+			return nil
 		})
 	}
 
 	return gp.Wait()
 }
 
+// GetIndexer returns indexer of every database in registration order.
 func (p *Pool) GetIndexer(ctx context.Context, indexerId string) ([]schema.Indexer, error) {
-	indexers := make([]schema.Indexer, 0)
+	items := p.engines()
+	indexers := make([]schema.Indexer, len(items))
 
 	gp, gpCtx := errgroup.WithContext(ctx)
 
-	for _, item := range p.items {
+	for i, item := range items {
 		gp.Go(func() error {
-			var indexer schema.Indexer
-
-			if err := item.FindOne(gpCtx, schema.IndexerTableName, "index_id", indexerId, &indexer); err != nil {
+			if err := item.FindOne(gpCtx, schema.IndexerTableName, "index_id", indexerId, &indexers[i]); err != nil {
 				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
 			}
 
-			indexers = append(indexers, indexer)
+			return nil
+		})
+	}
+
+	if err := gp.Wait(); err != nil {
+		return nil, err
+	}
+
+	return indexers, nil
+}
+
+// IndexerResult is indexer row of one database, Err is set when it can't be read.
+type IndexerResult struct {
+	Name    string
+	Engine  string
+	Indexer schema.Indexer
+	Err     error
+}
+
+// GetIndexers reads indexer row of every database like GetIndexer, but a failing database is reported in its
+// result and doesn't fail the others. Results keep registration order.
+func (p *Pool) GetIndexers(ctx context.Context, indexerId string) []IndexerResult {
+	items := p.engines()
+	results := make([]IndexerResult, len(items))
 
+	gp, gpCtx := errgroup.WithContext(ctx)
+	for i, item := range items {
+		gp.Go(func() error {
+			results[i] = IndexerResult{Name: item.Name(), Engine: item.Engine()}
+			if err := item.FindOne(gpCtx, schema.IndexerTableName, "index_id", indexerId,
+				&results[i].Indexer); err != nil {
+				results[i].Err = newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+
+			// a failing database must not cancel reads of others
 			return nil
 		})
 	}
+	_ = gp.Wait()
 
-	return indexers, gp.Wait()
+	return results
 }
 
+// InsertOne stores data in every database, table is chosen by type of data.
 func (p *Pool) InsertOne(ctx context.Context, dataPtr any) error {
+	table, err := tableOf(dataPtr)
+	if err != nil {
+		return err
+	}
+
 	gp, gpCtx := errgroup.WithContext(ctx)
 
-	for _, item := range p.items {
+	for _, item := range p.engines() {
 		gp.Go(func() error {
-			if err := item.InsertOne(gpCtx, schema.IndexerTableName, dataPtr); err != nil {
+			if err := item.InsertOne(gpCtx, table, copyRow(dataPtr)); err != nil {
 				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
 			}
 
@@ -100,12 +319,35 @@ func (p *Pool) InsertOne(ctx context.Context, dataPtr any) error {
 	return gp.Wait()
 }
 
+// InsertMany stores data in every database, all items must be of the same type which chooses the table.
 func (p *Pool) InsertMany(ctx context.Context, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	table, err := tableOf(dataPtr[0])
+	if err != nil {
+		return err
+	}
+
+	for _, data := range dataPtr[1:] {
+		if t, err := tableOf(data); err != nil || t != table {
+			return fmt.Errorf("can't insert %T and %T in one call", dataPtr[0], data)
+		}
+	}
+
+	return p.insert(ctx, table, dataPtr)
+}
+
+// insert fans rows out to every database, each engine gets its own copies.
+func (p *Pool) insert(ctx context.Context, table string, rows []any) error {
 	gp, gpCtx := errgroup.WithContext(ctx)
 
-	for _, item := range p.items {
+	for _, item := range p.engines() {
 		gp.Go(func() error {
-			if err := item.InsertMany(gpCtx, schema.IndexerTableName, dataPtr); err != nil {
+			defer metrics.ObserveWrite(item.Engine(), item.Name(), time.Now())
+
+			if err := item.InsertMany(gpCtx, table, copyRows(rows)); err != nil {
 				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
 			}
 
@@ -115,3 +357,233 @@ func (p *Pool) InsertMany(ctx context.Context, dataPtr []any) error {
 
 	return gp.Wait()
 }
+
+// upsert fans rows out to every database by unique key of table, each engine gets its own copies.
+func (p *Pool) upsert(ctx context.Context, table string, rows []any) error {
+	gp, gpCtx := errgroup.WithContext(ctx)
+
+	for _, item := range p.engines() {
+		gp.Go(func() error {
+			defer metrics.ObserveWrite(item.Engine(), item.Name(), time.Now())
+
+			if err := item.UpsertMany(gpCtx, table, uniqueKeys[table], copyRows(rows)); err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	return gp.Wait()
+}
+
+// WriteBlock stores block and its transactions in every database and sink, and outbox rows in the outbox
+// database, then moves the indexer cursor to the next height and checkpoints sinks. If any write fails sinks
+// are rolled back to the previous block and no cursor moves. Databases and sinks whose cursor is already past
+// the height are skipped, rows are upserted so writing a height again after a failure doesn't violate unique
+// keys. Outbox rows are only inserted when new, so a row already delivered isn't sent again.
+func (p *Pool) WriteBlock(ctx context.Context, indexerId string, block *schema.Block, txs []*schema.Transaction) error {
+	var outbox []any
+	if p.outbox != nil {
+		rows, err := p.outbox.OutboxRows(block, txs)
+		if err != nil {
+			return err
+		}
+		outbox = rows
+	}
+
+	sinks, err := p.sinksBehind(ctx, block.Height)
+	if err != nil {
+		return err
+	}
+
+	gp, gpCtx := errgroup.WithContext(ctx)
+
+	items := p.behind(block.Height)
+	for _, item := range items {
+		gp.Go(func() (err error) {
+			ctx, span := tracing.Tracer().Start(gpCtx, "write "+item.Engine(), trace.WithAttributes(
+				attribute.String("db.system", item.Engine()),
+				attribute.String("db.name", item.Name()),
+			))
+			defer func() { tracing.End(span, err) }()
+			defer metrics.ObserveWrite(item.Engine(), item.Name(), time.Now())
+
+			if err := atomic(ctx, item, func(ctx context.Context, tx Database) error {
+				if err := upsertBlock(ctx, tx, block, txs); err != nil {
+					return err
+				}
+
+				if item.Name() != p.outboxDB || len(outbox) == 0 {
+					return nil
+				}
+
+				table, err := tableOf(outbox[0])
+				if err != nil {
+					return err
+				}
+
+				return tx.InsertNew(ctx, table, uniqueKeys[table], outbox)
+			}); err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	for _, sink := range sinks {
+		gp.Go(func() (err error) {
+			ctx, span := tracing.Tracer().Start(gpCtx, "write sink", trace.WithAttributes(
+				attribute.String("sink.name", sink.Name())))
+			defer func() { tracing.End(span, err) }()
+
+			if err := sink.WriteBlock(ctx, block, txs); err != nil {
+				return newSinkErr(sink.Name(), err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	if err := gp.Wait(); err != nil {
+		for _, sink := range sinks {
+			if rbErr := sink.Rollback(ctx, block.Height-1); rbErr != nil {
+				err = errors.Join(err, newSinkErr(sink.Name(), rbErr.Error()))
+			}
+		}
+
+		return err
+	}
+
+	// cursors move only after every destination has the block
+	gp, gpCtx = errgroup.WithContext(ctx)
+	p.goUpdateCursors(gpCtx, gp, items, indexerId, block.Height)
+
+	for _, sink := range sinks {
+		gp.Go(func() error {
+			if err := sink.Checkpoint(gpCtx, block.Height); err != nil {
+				return newSinkErr(sink.Name(), err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	if err := gp.Wait(); err != nil {
+		return err
+	}
+
+	blockTime := time.Unix(int64(block.BlockTime), 0)
+	for _, item := range p.engines() {
+		metrics.SetIndexed(item.Name(), block.Height, blockTime)
+	}
+	for _, sink := range p.sinks {
+		metrics.SetIndexed(sink.Name(), block.Height, blockTime)
+	}
+
+	return nil
+}
+
+func (p *Pool) goUpdateCursors(ctx context.Context, gp *errgroup.Group, items []Database, indexerId string,
+	height uint32) {
+	for _, item := range items {
+		gp.Go(func() error {
+			if err := item.UpdateFields(ctx, schema.IndexerTableName, "index_id", indexerId, map[string]any{
+				"last_block_height": int(height) + 1,
+				"indexed_at":        time.Now(),
+			}); err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+			p.setCursor(item.Name(), height+1)
+
+			return nil
+		})
+	}
+}
+
+// behind returns databases whose cursor isn't past height, a database whose cursor is unknown is included.
+func (p *Pool) behind(height uint32) []Database {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	items := make([]Database, 0, len(p.items))
+	for _, item := range p.items {
+		if next, ok := p.cursors[item.Name()]; ok && next > height {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// sinksBehind returns sinks whose last checkpoint is before height, so a sink resuming from an older checkpoint
+// doesn't make the others write blocks twice.
+func (p *Pool) sinksBehind(ctx context.Context, height uint32) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(p.sinks))
+	for _, sink := range p.sinks {
+		cursor, err := sink.Cursor(ctx)
+		if err != nil {
+			return nil, newSinkErr(sink.Name(), err.Error())
+		}
+
+		if cursor >= height {
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+func (p *Pool) setCursor(name string, next uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cursors[name] = next
+}
+
+// atomic runs fn in a transaction on databases which support them, other databases rely on upserts, so fn can
+// run again after a failure.
+func atomic(ctx context.Context, item Database, fn func(ctx context.Context, tx Database) error) error {
+	if t, ok := item.(Transactor); ok {
+		return t.Transaction(ctx, fn)
+	}
+
+	return fn(ctx, item)
+}
+
+// upsertBlock stores block and its transactions, each engine gets its own copies.
+func upsertBlock(ctx context.Context, item Database, block *schema.Block, txs []*schema.Transaction) error {
+	if err := item.UpsertMany(ctx, schema.BlockTableName, uniqueKeys[schema.BlockTableName],
+		[]any{copyRow(block)}); err != nil {
+		return err
+	}
+
+	if len(txs) == 0 {
+		return nil
+	}
+
+	rows := make([]any, 0, len(txs))
+	for _, tx := range txs {
+		rows = append(rows, copyRow(tx))
+	}
+
+	return item.UpsertMany(ctx, schema.TransactionsTableName, uniqueKeys[schema.TransactionsTableName], rows)
+}
+
+// SinkCursors returns last checkpointed height of every sink.
+func (p *Pool) SinkCursors(ctx context.Context) ([]uint32, error) {
+	cursors := make([]uint32, 0, len(p.sinks))
+
+	for _, sink := range p.sinks {
+		c, err := sink.Cursor(ctx)
+		if err != nil {
+			return nil, newSinkErr(sink.Name(), err.Error())
+		}
+		cursors = append(cursors, c)
+	}
+
+	return cursors, nil
+}
@@ -0,0 +1,126 @@
+// Package dbtest has database fixtures shared by tests of packages which use db.
+package dbtest
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Logger returns a console logger for tests.
+func Logger(t testing.TB) logging.Logger {
+	t.Helper()
+	logger, err := logging.New(logging.ConsoleHandler, logging.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return logger
+}
+
+// Memory returns an empty memory database.
+func Memory(name string) db.Database {
+	return db.NewMemory(&schema.DB{Name: name, Type: schema.SQL, Engine: schema.MEMORY})
+}
+
+// SQLite returns a sqlite database in a temporary directory of t, it's closed when t ends.
+func SQLite(t testing.TB, name string) db.Database {
+	t.Helper()
+	database, err := db.NewSQL(context.Background(), &schema.DB{
+		Name:   name,
+		Type:   schema.SQL,
+		Engine: schema.SQLITE,
+		URI:    "file:" + filepath.Join(t.TempDir(), name+".db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	return database
+}
+
+// Pool returns a pool of databases, migrated for indexerId from height 1 unless indexerId is empty.
+func Pool(t testing.TB, indexerId string, databases ...db.Database) *db.Pool {
+	t.Helper()
+	p := db.NewPool(Logger(t))
+	for _, d := range databases {
+		p.RegisterEngine(d)
+	}
+
+	if len(indexerId) != 0 {
+		if _, err := p.Migration(context.Background(), indexerId, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return p
+}
+
+// MemoryPool returns a pool of memory databases named names, it isn't migrated.
+func MemoryPool(t testing.TB, names ...string) *db.Pool {
+	t.Helper()
+	databases := make([]db.Database, 0, len(names))
+	for _, name := range names {
+		databases = append(databases, Memory(name))
+	}
+
+	return Pool(t, "", databases...)
+}
+
+// Engines returns sqlite and memory databases, clickhouse and mongodb are added when CLICKHOUSE_URI or
+// MONGODB_URI is set. Rows left in them by earlier runs are deleted.
+func Engines(t testing.TB) []db.Database {
+	t.Helper()
+	ctx := context.Background()
+	engines := []db.Database{SQLite(t, "sqlite"), Memory("memory")}
+
+	external := []*schema.DB{
+		{Name: "clickhouse", Type: schema.SQL, Engine: schema.CLICKHOUSE, URI: os.Getenv("CLICKHOUSE_URI")},
+		{Name: "mongodb", Type: schema.NOSQL, Engine: schema.MONGODB, URI: os.Getenv("MONGODB_URI"),
+			Database: "indexer_repository_test"},
+	}
+
+	for _, cfg := range external {
+		if len(cfg.URI) == 0 {
+			continue
+		}
+
+		database, err := db.Open(ctx, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = database.Close() })
+
+		if err := reset(ctx, database); err != nil {
+			t.Fatal(err)
+		}
+		engines = append(engines, database)
+	}
+
+	return engines
+}
+
+// reset deletes every row of database tables.
+func reset(ctx context.Context, database db.Database) error {
+	if err := database.Migrate(ctx); err != nil {
+		return err
+	}
+
+	for table, key := range map[string]string{
+		schema.BlockTableName:         "height",
+		schema.TransactionsTableName:  "block_height",
+		schema.WebhookOutboxTableName: "block_height",
+	} {
+		if err := database.DeleteRange(ctx, table, key, 0, math.MaxUint32); err != nil {
+			return err
+		}
+	}
+
+	return database.DeleteRange(ctx, schema.IndexerTableName, "index_id", "", "\U0010FFFF")
+}
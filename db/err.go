@@ -1,6 +1,12 @@
 package db
 
-import "fmt"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
 
 type Err struct {
 	Name   string
@@ -21,3 +27,13 @@ func newErr(name, engine, dbType, msg string) *Err {
 		Msg:    msg,
 	}
 }
+
+func newSinkErr(name, msg string) *Err {
+	return newErr(name, "sink", "sink", msg)
+}
+
+// IsNotFound reports whether err means FindOne found nothing, whatever the engine.
+func IsNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments) ||
+		errors.Is(err, sql.ErrNoRows)
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"golang.org/x/sync/errgroup"
+	"time"
+)
+
+const _defaultKeepAliveInterval = 10 * time.Second
+
+// Health is result of pinging a database.
+type Health struct {
+	Name    string
+	Engine  string
+	Latency time.Duration
+	Err     error
+}
+
+// Open connects to database by its engine.
+func Open(ctx context.Context, dbCfg *schema.DB) (Database, error) {
+	switch dbCfg.Engine {
+	case schema.MARIADB, schema.MYSQL, schema.POSTGRESQL, schema.SQLITE:
+		return NewSQL(ctx, dbCfg)
+	case schema.CLICKHOUSE:
+		return NewClickHouse(ctx, dbCfg)
+	case schema.MONGODB:
+		return NewMongodb(ctx, dbCfg)
+	case schema.MEMORY:
+		return NewMemory(dbCfg), nil
+	}
+
+	return nil, fmt.Errorf("database engine %s is invalid", dbCfg.Engine)
+}
+
+// Open connects to every database and registers them, databases opened so far are closed when one fails.
+func (p *Pool) Open(ctx context.Context, dbs ...*schema.DB) error {
+	opened := make([]Database, 0, len(dbs))
+
+	for _, d := range dbs {
+		database, err := Open(ctx, d)
+		if err != nil {
+			errs := []error{newErr(d.Name, d.Engine.String(), d.Type.String(), err.Error())}
+			for _, o := range opened {
+				errs = append(errs, o.Close())
+			}
+
+			return errors.Join(errs...)
+		}
+		opened = append(opened, database)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.items = append(p.items, opened...)
+
+	return nil
+}
+
+// Ping checks every database concurrently, results keep registration order.
+func (p *Pool) Ping(ctx context.Context) []Health {
+	items := p.engines()
+	health := make([]Health, len(items))
+
+	gp, gpCtx := errgroup.WithContext(ctx)
+	for i, item := range items {
+		gp.Go(func() error {
+			start := time.Now()
+			err := item.Ping(gpCtx)
+			health[i] = Health{
+				Name:    item.Name(),
+				Engine:  item.Engine(),
+				Latency: time.Since(start),
+				Err:     err,
+			}
+
+			// a failing database must not cancel pings of others
+			return nil
+		})
+	}
+	_ = gp.Wait()
+
+	return health
+}
+
+// KeepAlive pings every database on each interval until context is canceled and logs when a database goes down
+// or comes back, zero interval means default of 10 seconds. Drivers of every engine reconnect by themselves,
+// so connections are never replaced while operations may still use them.
+func (p *Pool) KeepAlive(ctx context.Context, interval time.Duration) error {
+	if interval == 0 {
+		interval = _defaultKeepAliveInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	down := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		for _, h := range p.Ping(ctx) {
+			if ctx.Err() != nil {
+				break
+			}
+
+			if h.Err == nil {
+				if down[h.Name] {
+					delete(down, h.Name)
+					p.logging.InfoContext(ctx, false, "Database is reachable again", "db", h.Name)
+				}
+				continue
+			}
+
+			// report to sentry only when database goes down, not on every failed ping
+			p.logging.ErrorContext(ctx, !down[h.Name], "database is unreachable",
+				"db", h.Name, "engine", h.Engine, "retry_in", interval.String(), "err", h.Err)
+			down[h.Name] = true
+		}
+	}
+}
+
+// Executor returns executor of database by name.
+func (p *Pool) Executor(name string) (Executor, bool) {
+	return p.Engine(name)
+}
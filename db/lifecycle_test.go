@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closeFailing is a database whose Close fails.
+type closeFailing struct {
+	Database
+	closed *int
+}
+
+func (c closeFailing) Close() error {
+	*c.closed++
+	return errors.New("close failed")
+}
+
+func TestPool_CloseAttemptsEveryEngine(t *testing.T) {
+	closed := 0
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(closeFailing{Database: setupMemory("first"), closed: &closed})
+	p.RegisterEngine(closeFailing{Database: setupMemory("second"), closed: &closed})
+
+	err := p.Close()
+	if closed != 2 {
+		t.Fatalf("expected both engines closed, got %d", closed)
+	}
+
+	var dbErr *Err
+	if !errors.As(err, &dbErr) || len(err.(interface{ Unwrap() []error }).Unwrap()) != 2 {
+		t.Fatalf("expected joined errors of both engines, got %v", err)
+	}
+}
+
+// flakyPing fails pings while down is set.
+type flakyPing struct {
+	Database
+	m      sync.Mutex
+	down   bool
+	closed bool
+}
+
+func (f *flakyPing) setDown(down bool) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.down = down
+}
+
+func (f *flakyPing) Ping(context.Context) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.down {
+		return errors.New("connection refused")
+	}
+
+	return nil
+}
+
+func (f *flakyPing) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.closed = true
+
+	return nil
+}
+
+func TestPool_KeepAliveKeepsConnection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flaky := &flakyPing{Database: setupMemory("memory")}
+	flaky.setDown(true)
+
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(flaky)
+
+	done := make(chan error)
+	go func() { done <- p.KeepAlive(ctx, time.Millisecond) }()
+
+	time.Sleep(10 * time.Millisecond)
+	flaky.setDown(false)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	// the driver reconnects by itself, a database still in use is never swapped or closed
+	if database, _ := p.Engine("memory"); database != flaky || flaky.closed {
+		t.Fatal("database was replaced while unreachable")
+	}
+}
@@ -0,0 +1,673 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrDuplicateKey = errors.New("duplicate key value violates unique constraint")
+
+// memoryTable keeps rows as struct values, fields are addressed by their bson name and gorm uniqueIndex
+// fields are unique like in other engines.
+type memoryTable struct {
+	rowType reflect.Type
+	fields  map[string]int
+	unique  []string
+	index   map[string]map[any]int // row position by value of every unique field
+	rows    []reflect.Value
+	nextId  uint
+}
+
+// Memory is a Database which keeps everything in maps, nothing outlives the process. It's safe for
+// concurrent use and meant for tests and dry runs.
+type Memory struct {
+	engine schema.DatabaseEngine
+	dbType schema.DatabaseType
+	name   string
+	tables map[string]*memoryTable
+	m      sync.RWMutex
+}
+
+func NewMemory(dbCfg *schema.DB) Database {
+	return &Memory{
+		engine: dbCfg.Engine,
+		dbType: dbCfg.Type,
+		name:   dbCfg.Name,
+		tables: make(map[string]*memoryTable),
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Ping always succeeds, there is no backend to lose.
+func (m *Memory) Ping(_ context.Context) error {
+	return nil
+}
+
+// Migrate does nothing, tables are created on first insert.
+func (m *Memory) Migrate(_ context.Context) error {
+	return nil
+}
+
+func (m *Memory) Name() string {
+	return m.name
+}
+
+func (m *Memory) Type() string {
+	return m.dbType.String()
+}
+
+func (m *Memory) Engine() string {
+	return m.engine.String()
+}
+
+func (m *Memory) FindOne(_ context.Context, tableOrCollectionName string, key string, val any, resultPtr any) error {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	rows, err := m.match(tableOrCollectionName, key, func(v reflect.Value) bool { return equal(v, val) })
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return sql.ErrNoRows
+	}
+
+	return assign(reflect.ValueOf(resultPtr).Elem(), rows[0])
+}
+
+func (m *Memory) FindMany(_ context.Context, tableOrCollectionName string, key string, val any,
+	resultSlicePtr any) error {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	rows, err := m.match(tableOrCollectionName, key, func(v reflect.Value) bool { return equal(v, val) })
+	if err != nil {
+		return err
+	}
+
+	return appendRows(resultSlicePtr, rows)
+}
+
+// FindRange finds rows which key is between from and to inclusive, ordered by key.
+func (m *Memory) FindRange(_ context.Context, tableOrCollectionName string, key string, from, to any,
+	resultSlicePtr any) error {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	rows, err := m.match(tableOrCollectionName, key, func(v reflect.Value) bool {
+		return compare(v, from) >= 0 && compare(v, to) <= 0
+	})
+	if err != nil {
+		return err
+	}
+
+	t := m.tables[tableOrCollectionName]
+	slices.SortStableFunc(rows, func(a, b reflect.Value) int {
+		return compare(a.Field(t.fields[key]), b.Field(t.fields[key]).Interface())
+	})
+
+	return appendRows(resultSlicePtr, rows)
+}
+
+// FindPage finds at most limit rows matching key which orderKey is greater than after, ordered by orderKey.
+func (m *Memory) FindPage(_ context.Context, tableOrCollectionName string, key string, val any, orderKey string,
+	after any, limit int, resultSlicePtr any) error {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		return nil
+	}
+
+	order, ok := t.fields[orderKey]
+	if !ok {
+		return fmt.Errorf("memory: unknown field %s in %s", orderKey, tableOrCollectionName)
+	}
+
+	rows, err := m.match(tableOrCollectionName, key, func(v reflect.Value) bool { return equal(v, val) })
+	if err != nil {
+		return err
+	}
+
+	rows = slices.DeleteFunc(rows, func(row reflect.Value) bool { return compare(row.Field(order), after) <= 0 })
+	slices.SortStableFunc(rows, func(a, b reflect.Value) int {
+		return compare(a.Field(order), b.Field(order).Interface())
+	})
+
+	return appendRows(resultSlicePtr, rows[:min(limit, len(rows))])
+}
+
+// Count returns number of rows in table, missing table has no rows.
+func (m *Memory) Count(_ context.Context, tableOrCollectionName string) (int64, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		return 0, nil
+	}
+
+	return int64(len(t.rows)), nil
+}
+
+// CountRange returns number of rows which key is between from and to inclusive.
+func (m *Memory) CountRange(_ context.Context, tableOrCollectionName string, key string, from, to any) (int64,
+	error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	rows, err := m.match(tableOrCollectionName, key, func(v reflect.Value) bool {
+		return compare(v, from) >= 0 && compare(v, to) <= 0
+	})
+
+	return int64(len(rows)), err
+}
+
+// SumRange returns sum of numeric field of rows which key is between from and to inclusive.
+func (m *Memory) SumRange(_ context.Context, tableOrCollectionName string, key string, from, to any,
+	field string) (int64, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	rows, err := m.match(tableOrCollectionName, key, func(v reflect.Value) bool {
+		return compare(v, from) >= 0 && compare(v, to) <= 0
+	})
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	idx, ok := m.tables[tableOrCollectionName].fields[field]
+	if !ok {
+		return 0, fmt.Errorf("memory: unknown field %s in %s", field, tableOrCollectionName)
+	}
+
+	var sum int64
+	for _, row := range rows {
+		switch v := row.Field(idx); {
+		case isInt(v):
+			sum += v.Int()
+		case isUint(v):
+			sum += int64(v.Uint())
+		default:
+			return 0, fmt.Errorf("memory: field %s of %s isn't numeric", field, tableOrCollectionName)
+		}
+	}
+
+	return sum, nil
+}
+
+// DeleteRange deletes rows which key is between from and to inclusive.
+func (m *Memory) DeleteRange(_ context.Context, tableOrCollectionName string, key string, from, to any) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		return nil
+	}
+
+	idx, ok := t.fields[key]
+	if !ok {
+		return fmt.Errorf("memory: unknown field %s in %s", key, tableOrCollectionName)
+	}
+
+	t.rows = slices.DeleteFunc(t.rows, func(row reflect.Value) bool {
+		return compare(row.Field(idx), from) >= 0 && compare(row.Field(idx), to) <= 0
+	})
+	t.reindex()
+
+	return nil
+}
+
+func (m *Memory) InsertOne(ctx context.Context, tableOrCollectionName string, dataPtr any) error {
+	return m.InsertMany(ctx, tableOrCollectionName, []any{dataPtr})
+}
+
+// InsertMany inserts all rows or none of them when a unique key is violated.
+func (m *Memory) InsertMany(_ context.Context, tableOrCollectionName string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		t = newMemoryTable(reflect.TypeOf(dataPtr[0]).Elem())
+		m.tables[tableOrCollectionName] = t
+	}
+
+	rows, err := t.rowsOf(tableOrCollectionName, dataPtr)
+	if err != nil {
+		return err
+	}
+
+	batch := t.newIndex()
+	for _, row := range rows {
+		if err := t.checkUnique(row, batch, nil); err != nil {
+			return err
+		}
+	}
+
+	for i, row := range rows {
+		t.setId(row, dataPtr[i])
+		t.add(row)
+	}
+
+	return nil
+}
+
+// UpsertMany inserts rows or replaces stored rows with the same key, which must be a unique field. All rows
+// are stored or none of them when another unique field is violated.
+func (m *Memory) UpsertMany(_ context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		t = newMemoryTable(reflect.TypeOf(dataPtr[0]).Elem())
+		m.tables[tableOrCollectionName] = t
+	}
+
+	byKey, ok := t.index[key]
+	if !ok {
+		return fmt.Errorf("memory: %s isn't a unique field of %s", key, tableOrCollectionName)
+	}
+
+	rows, err := t.rowsOf(tableOrCollectionName, dataPtr)
+	if err != nil {
+		return err
+	}
+
+	batch := t.newIndex()
+	replaced := make(map[int]bool)
+	for _, row := range rows {
+		if pos, ok := byKey[row.Field(t.fields[key]).Interface()]; ok {
+			replaced[pos] = true
+		}
+	}
+
+	for _, row := range rows {
+		if err := t.checkUnique(row, batch, replaced); err != nil {
+			return err
+		}
+	}
+
+	for i, row := range rows {
+		pos, ok := byKey[row.Field(t.fields[key]).Interface()]
+		if !ok {
+			t.setId(row, dataPtr[i])
+			t.add(row)
+			continue
+		}
+
+		// replaced row keeps its primary key
+		if id, ok := t.fields["id"]; ok {
+			row.Field(id).Set(t.rows[pos].Field(id))
+			reflect.ValueOf(dataPtr[i]).Elem().Field(id).Set(t.rows[pos].Field(id))
+		}
+		t.unindexRow(t.rows[pos])
+		t.rows[pos] = row
+		t.indexRow(row, pos)
+	}
+
+	return nil
+}
+
+// InsertNew inserts rows which key, a unique field, isn't stored yet, stored rows are kept. All new rows are
+// stored or none of them when another unique field is violated.
+func (m *Memory) InsertNew(_ context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		t = newMemoryTable(reflect.TypeOf(dataPtr[0]).Elem())
+		m.tables[tableOrCollectionName] = t
+	}
+
+	byKey, ok := t.index[key]
+	if !ok {
+		return fmt.Errorf("memory: %s isn't a unique field of %s", key, tableOrCollectionName)
+	}
+
+	rows, err := t.rowsOf(tableOrCollectionName, dataPtr)
+	if err != nil {
+		return err
+	}
+
+	added := make([]int, 0, len(rows))
+	batch := t.newIndex()
+	for i, row := range rows {
+		if _, ok := byKey[row.Field(t.fields[key]).Interface()]; ok {
+			continue
+		}
+
+		if err := t.checkUnique(row, batch, nil); err != nil {
+			return err
+		}
+		added = append(added, i)
+	}
+
+	for _, i := range added {
+		t.setId(rows[i], dataPtr[i])
+		t.add(rows[i])
+	}
+
+	return nil
+}
+
+func (m *Memory) UpdateOne(ctx context.Context, tableOrCollectionName string, key string, val any,
+	updKey string, updVal any) error {
+	return m.UpdateFields(ctx, tableOrCollectionName, key, val, map[string]any{updKey: updVal})
+}
+
+func (m *Memory) UpdateFields(_ context.Context, tableOrCollectionName string, key string, val any,
+	fields map[string]any) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	t, ok := m.tables[tableOrCollectionName]
+	if !ok {
+		return nil
+	}
+
+	idx, ok := t.fields[key]
+	if !ok {
+		return fmt.Errorf("memory: unknown field %s in %s", key, tableOrCollectionName)
+	}
+
+	// only matching rows are copied, they replace stored ones once every unique field is checked
+	updated := make(map[int]reflect.Value)
+	for i, row := range t.rows {
+		if !equal(row.Field(idx), val) {
+			continue
+		}
+
+		row = clone(row)
+		for name, fv := range fields {
+			f, ok := t.fields[name]
+			if !ok {
+				return fmt.Errorf("memory: unknown field %s in %s", name, tableOrCollectionName)
+			}
+
+			v, err := convert(reflect.ValueOf(fv), row.Field(f).Type())
+			if err != nil {
+				return fmt.Errorf("memory: field %s: %w", name, err)
+			}
+			row.Field(f).Set(v)
+		}
+		updated[i] = row
+	}
+
+	batch := t.newIndex()
+	replaced := make(map[int]bool, len(updated))
+	for i := range updated {
+		replaced[i] = true
+	}
+
+	for _, row := range updated {
+		if err := t.checkUnique(row, batch, replaced); err != nil {
+			return err
+		}
+	}
+
+	for i := range updated {
+		t.unindexRow(t.rows[i])
+	}
+	for i, row := range updated {
+		t.rows[i] = row
+		t.indexRow(row, i)
+	}
+
+	return nil
+}
+
+// match returns copy of rows which key field matches, missing table has no rows.
+func (m *Memory) match(table, key string, fn func(v reflect.Value) bool) ([]reflect.Value, error) {
+	t, ok := m.tables[table]
+	if !ok {
+		return nil, nil
+	}
+
+	idx, ok := t.fields[key]
+	if !ok {
+		return nil, fmt.Errorf("memory: unknown field %s in %s", key, table)
+	}
+
+	rows := make([]reflect.Value, 0)
+	for _, row := range t.rows {
+		if fn(row.Field(idx)) {
+			rows = append(rows, clone(row))
+		}
+	}
+
+	return rows, nil
+}
+
+func newMemoryTable(rowType reflect.Type) *memoryTable {
+	t := &memoryTable{
+		rowType: rowType,
+		fields:  make(map[string]int, rowType.NumField()),
+		index:   make(map[string]map[any]int),
+	}
+
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if name == "-" && strings.Contains(field.Tag.Get("gorm"), "primarykey") {
+			name = "id"
+		}
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+
+		t.fields[name] = i
+		if strings.Contains(field.Tag.Get("gorm"), "uniqueIndex") {
+			t.unique = append(t.unique, name)
+			t.index[name] = make(map[any]int)
+		}
+	}
+
+	return t
+}
+
+// rowsOf copies dataPtr into rows of table.
+func (t *memoryTable) rowsOf(table string, dataPtr []any) ([]reflect.Value, error) {
+	rows := make([]reflect.Value, 0, len(dataPtr))
+	for _, d := range dataPtr {
+		v := reflect.ValueOf(d)
+		if v.Kind() != reflect.Pointer || v.Elem().Type() != t.rowType {
+			return nil, fmt.Errorf("memory: %s expects *%s, got %T", table, t.rowType, d)
+		}
+		rows = append(rows, clone(v.Elem()))
+	}
+
+	return rows, nil
+}
+
+// checkUnique checks unique fields of row against stored rows, except the replaced positions, and against rows
+// of the same batch which are added to batch.
+func (t *memoryTable) checkUnique(row reflect.Value, batch map[string]map[any]int, replaced map[int]bool) error {
+	for _, name := range t.unique {
+		v := row.Field(t.fields[name]).Interface()
+
+		pos, stored := t.index[name][v]
+		if _, dup := batch[name][v]; dup || (stored && !replaced[pos]) {
+			return fmt.Errorf("%w: %s = %v", ErrDuplicateKey, name, v)
+		}
+		batch[name][v] = 0
+	}
+
+	return nil
+}
+
+func (t *memoryTable) newIndex() map[string]map[any]int {
+	index := make(map[string]map[any]int, len(t.unique))
+	for _, name := range t.unique {
+		index[name] = make(map[any]int)
+	}
+
+	return index
+}
+
+// setId sets primary key of row and the inserted value like gorm does.
+func (t *memoryTable) setId(row reflect.Value, dataPtr any) {
+	if id, ok := t.fields["id"]; ok {
+		t.nextId++
+		row.Field(id).SetUint(uint64(t.nextId))
+		reflect.ValueOf(dataPtr).Elem().Field(id).SetUint(uint64(t.nextId))
+	}
+}
+
+func (t *memoryTable) add(row reflect.Value) {
+	t.rows = append(t.rows, row)
+	t.indexRow(row, len(t.rows)-1)
+}
+
+func (t *memoryTable) indexRow(row reflect.Value, pos int) {
+	for _, name := range t.unique {
+		t.index[name][row.Field(t.fields[name]).Interface()] = pos
+	}
+}
+
+func (t *memoryTable) unindexRow(row reflect.Value) {
+	for _, name := range t.unique {
+		delete(t.index[name], row.Field(t.fields[name]).Interface())
+	}
+}
+
+// reindex rebuilds unique indexes after rows are removed and positions move.
+func (t *memoryTable) reindex() {
+	t.index = t.newIndex()
+	for i, row := range t.rows {
+		t.indexRow(row, i)
+	}
+}
+
+// clone copies row, slices are copied too so callers can't change stored rows.
+func clone(row reflect.Value) reflect.Value {
+	c := reflect.New(row.Type()).Elem()
+	c.Set(row)
+
+	for i := 0; i < c.NumField(); i++ {
+		f := c.Field(i)
+		if f.Kind() == reflect.Slice && !f.IsNil() {
+			s := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			reflect.Copy(s, f)
+			f.Set(s)
+		}
+	}
+
+	return c
+}
+
+func assign(dest, row reflect.Value) error {
+	if dest.Type() != row.Type() {
+		return fmt.Errorf("memory: can't read %s into %s", row.Type(), dest.Type())
+	}
+	dest.Set(row)
+
+	return nil
+}
+
+func appendRows(resultSlicePtr any, rows []reflect.Value) error {
+	slice := reflect.ValueOf(resultSlicePtr).Elem()
+	elemType := slice.Type().Elem()
+
+	for _, row := range rows {
+		elem := reflect.New(elemType).Elem()
+		dest := elem
+		if elemType.Kind() == reflect.Pointer {
+			elem.Set(reflect.New(elemType.Elem()))
+			dest = elem.Elem()
+		}
+
+		if err := assign(dest, row); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+
+	return nil
+}
+
+func equal(v reflect.Value, val any) bool {
+	return compare(v, val) == 0
+}
+
+// compare compares field value with val of any numeric, string or time type, values which can't
+// be compared are never equal.
+func compare(v reflect.Value, val any) int {
+	o := reflect.ValueOf(val)
+
+	switch {
+	case isInt(v) && isInt(o):
+		return cmp.Compare(v.Int(), o.Int())
+	case isUint(v) && isUint(o):
+		return cmp.Compare(v.Uint(), o.Uint())
+	case isInt(v) && isUint(o):
+		return compareIntUint(v.Int(), o.Uint())
+	case isUint(v) && isInt(o):
+		return -compareIntUint(o.Int(), v.Uint())
+	case v.Kind() == reflect.String && o.Kind() == reflect.String:
+		return strings.Compare(v.String(), o.String())
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if ot, ok := val.(time.Time); ok {
+			return t.Compare(ot)
+		}
+	}
+
+	return -2
+}
+
+func compareIntUint(i int64, u uint64) int {
+	if i < 0 {
+		return -1
+	}
+
+	return cmp.Compare(uint64(i), u)
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
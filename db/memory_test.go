@@ -0,0 +1,270 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"sync"
+	"testing"
+)
+
+func setupMemory(name string) Database {
+	return NewMemory(&schema.DB{Name: name, Type: schema.SQL, Engine: schema.MEMORY})
+}
+
+func TestMemory_UniqueKeys(t *testing.T) {
+	ctx := context.Background()
+	m := setupMemory("memory")
+
+	if err := m.InsertOne(ctx, schema.BlockTableName, &schema.Block{Height: 1, Hash: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.InsertOne(ctx, schema.BlockTableName, &schema.Block{Height: 1, Hash: "b"}); !errors.Is(err,
+		ErrDuplicateKey) {
+		t.Fatalf("expected duplicate height error, got %v", err)
+	}
+
+	// batch with a duplicate inside must not insert anything
+	err := m.InsertMany(ctx, schema.TransactionsTableName, []any{
+		&schema.Transaction{Hash: "x", BlockHeight: 1},
+		&schema.Transaction{Hash: "x", BlockHeight: 1},
+	})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected duplicate hash error, got %v", err)
+	}
+
+	txs := make([]schema.Transaction, 0)
+	if err := m.FindMany(ctx, schema.TransactionsTableName, "block_height", 1, &txs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txs) != 0 {
+		t.Fatalf("expected no transactions, got %d", len(txs))
+	}
+
+	if err := m.UpdateOne(ctx, schema.BlockTableName, "height", 1, "hash", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	var block schema.Block
+	if err := m.FindOne(ctx, schema.BlockTableName, "hash", "c", &block); err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Height != 1 || block.ID != 1 {
+		t.Fatalf("unexpected block %+v", block)
+	}
+
+	if err := m.InsertOne(ctx, schema.BlockTableName, &schema.Block{Height: 2, Hash: "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// an update violating a unique key changes nothing
+	if err := m.UpdateOne(ctx, schema.BlockTableName, "height", 2, "hash", "c"); !errors.Is(err,
+		ErrDuplicateKey) {
+		t.Fatalf("expected duplicate hash error, got %v", err)
+	}
+
+	if err := m.FindOne(ctx, schema.BlockTableName, "height", 2, &block); err != nil || block.Hash != "d" {
+		t.Fatalf("expected block 2 unchanged, got %+v, %v", block, err)
+	}
+
+	// deleted keys can be used again
+	if err := m.DeleteRange(ctx, schema.BlockTableName, "height", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.InsertOne(ctx, schema.BlockTableName, &schema.Block{Height: 1, Hash: "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.InsertOne(ctx, schema.BlockTableName, &schema.Block{Height: 3, Hash: "d"}); !errors.Is(err,
+		ErrDuplicateKey) {
+		t.Fatalf("expected duplicate hash of a moved row, got %v", err)
+	}
+
+	if err := m.UpsertMany(ctx, schema.BlockTableName, "height", []any{
+		&schema.Block{Height: 1, Hash: "e"},
+		&schema.Block{Height: 3, Hash: "f"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var upserted schema.Block
+	if err := m.FindOne(ctx, schema.BlockTableName, "height", 1, &upserted); err != nil || upserted.Hash != "e" ||
+		upserted.ID != 3 {
+		t.Fatalf("expected block 1 replaced keeping its id, got %+v, %v", upserted, err)
+	}
+
+	err = m.UpsertMany(ctx, schema.BlockTableName, "height", []any{&schema.Block{Height: 4, Hash: "d"}})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected duplicate hash error, got %v", err)
+	}
+}
+
+func TestMemory_PoolFanOut(t *testing.T) {
+	ctx := context.Background()
+	first, second := setupMemory("first"), setupMemory("second")
+
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(first)
+	p.RegisterEngine(second)
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// readers run while blocks are written
+	wg := sync.WaitGroup{}
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if _, err := p.GetCursor(ctx, testIndexerId); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	for h := uint32(1); h <= 10; h++ {
+		block := &schema.Block{Height: h, Hash: string(rune('a' + h))}
+		txs := []*schema.Transaction{{Hash: string(rune('k' + h)), BlockHeight: h}}
+
+		if err := p.WriteBlock(ctx, testIndexerId, block, txs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	for _, m := range []Database{first, second} {
+		blocks := make([]*schema.Block, 0)
+		if err := m.FindRange(ctx, schema.BlockTableName, "height", 3, 5, &blocks); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(blocks) != 3 || blocks[0].Height != 3 || blocks[2].Height != 5 {
+			t.Fatalf("%s: expected blocks 3 to 5, got %v", m.Name(), blocks)
+		}
+	}
+}
+
+// testOutbox builds one row per block, or fails when err is set.
+type testOutbox struct {
+	err error
+}
+
+func (o testOutbox) OutboxRows(block *schema.Block, _ []*schema.Transaction) ([]any, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	return []any{&schema.WebhookOutbox{EventId: block.Hash, BlockHeight: block.Height}}, nil
+}
+
+func TestPool_WriteBlockOutbox(t *testing.T) {
+	ctx := context.Background()
+	first, second := setupMemory("first"), setupMemory("second")
+
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(first)
+	p.RegisterEngine(second)
+
+	if err := p.SetOutbox("third", testOutbox{}); err == nil {
+		t.Fatal("expected error for unregistered outbox database")
+	}
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SetOutbox("first", testOutbox{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.WriteBlock(ctx, testIndexerId, &schema.Block{Height: 1, Hash: "a"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		db   Database
+		want int
+	}{{first, 1}, {second, 0}} {
+		rows := make([]*schema.WebhookOutbox, 0)
+		if err := tc.db.FindMany(ctx, schema.WebhookOutboxTableName, "block_height", 1, &rows); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != tc.want {
+			t.Fatalf("%s: expected %d outbox rows, got %d", tc.db.Name(), tc.want, len(rows))
+		}
+	}
+
+	// a block whose outbox rows can't be built isn't written and the cursor stays
+	if err := p.SetOutbox("first", testOutbox{err: errors.New("boom")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.WriteBlock(ctx, testIndexerId, &schema.Block{Height: 2, Hash: "b"}, nil); err == nil {
+		t.Fatal("expected outbox error")
+	}
+
+	cursors, err := p.GetCursor(ctx, testIndexerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cursor := range cursors {
+		if cursor != 2 {
+			t.Fatalf("%s: expected cursor 2, got %d", name, cursor)
+		}
+	}
+}
+
+// testSink records written heights, its cursor is fixed.
+type testSink struct {
+	name    string
+	cursor  uint32
+	written []uint32
+}
+
+func (s *testSink) Name() string { return s.name }
+
+func (s *testSink) WriteBlock(_ context.Context, block *schema.Block, _ []*schema.Transaction) error {
+	s.written = append(s.written, block.Height)
+	return nil
+}
+
+func (s *testSink) Rollback(context.Context, uint32) error   { return nil }
+func (s *testSink) Checkpoint(context.Context, uint32) error { return nil }
+func (s *testSink) Cursor(context.Context) (uint32, error)   { return s.cursor, nil }
+func (s *testSink) Close() error                             { return nil }
+
+func TestPool_WriteBlockSkipsSinksPastHeight(t *testing.T) {
+	ctx := context.Background()
+
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(setupMemory("memory"))
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// a parquet sink resumes from its last part while the other sink has the blocks already
+	behind, ahead := &testSink{name: "behind", cursor: 1}, &testSink{name: "ahead", cursor: 3}
+	p.RegisterSink(behind)
+	p.RegisterSink(ahead)
+
+	for h := uint32(2); h <= 4; h++ {
+		if err := p.WriteBlock(ctx, testIndexerId, &schema.Block{Height: h, Hash: string(rune('a' + h))},
+			nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(behind.written) != 3 || len(ahead.written) != 1 || ahead.written[0] != 4 {
+		t.Fatalf("unexpected writes, behind %v ahead %v", behind.written, ahead.written)
+	}
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"math"
+	"time"
+)
+
+// MigrationVersion is a numbered schema change, every engine with versioned migrations implements the same
+// versions so databases of a pool can be compared.
+type MigrationVersion struct {
+	Version     uint
+	Description string
+}
+
+var Migrations = []MigrationVersion{
+	{Version: 1, Description: "blocks, transactions and indexers"},
+	{Version: 2, Description: "webhook outbox"},
+}
+
+// LatestMigration returns the newest migration version.
+func LatestMigration() uint {
+	return Migrations[len(Migrations)-1].Version
+}
+
+type MigrationStatus struct {
+	MigrationVersion
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator is implemented by databases with versioned, reversible migrations.
+type Migrator interface {
+	// MigrateTo applies up migrations until version, or reverts applied migrations newer than version.
+	MigrateTo(ctx context.Context, version uint) error
+	// MigrationStatus returns every known migration with its state.
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+type IndexerAction string
+
+const (
+	IndexerCreated     IndexerAction = "created"
+	IndexerSkipped     IndexerAction = "skipped"
+	IndexerCursorReset IndexerAction = "cursor reset"
+)
+
+// MigrationReport is what a migration run changed in a database.
+type MigrationReport struct {
+	Database string
+	// Applied are migration versions applied by this run, always empty for engines without versioned migrations.
+	Applied []uint
+	Version uint
+	Indexer IndexerAction
+	// PrevBlockHeight is cursor of existing indexer before migration.
+	PrevBlockHeight int
+	LastBlockHeight int
+}
+
+// migrateDatabase migrates database schema and creates indexer row if it doesn't exist. Resetting cursor of an
+// existing indexer deletes blocks and transactions from the new cursor on.
+func migrateDatabase(ctx context.Context, database Database, indexerUUid string, lastBlockHeight int,
+	resetCursor bool) (*MigrationReport, error) {
+	report := &MigrationReport{Database: database.Name()}
+
+	migrator, versioned := database.(Migrator)
+	var before []MigrationStatus
+	if versioned {
+		status, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		before = status
+	}
+
+	if err := database.Migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	if versioned {
+		after, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, st := range after {
+			if st.Applied && !before[i].Applied {
+				report.Applied = append(report.Applied, st.Version)
+			}
+		}
+		report.Version = CurrentVersion(after)
+	}
+
+	var indexer schema.Indexer
+	err := database.FindOne(ctx, schema.IndexerTableName, "index_id", indexerUUid, &indexer)
+	switch {
+	case IsNotFound(err):
+		if err := database.InsertOne(ctx, schema.IndexerTableName, &schema.Indexer{
+			IndexId:         indexerUUid,
+			LastBlockHeight: lastBlockHeight,
+			IndexedAt:       time.Now(),
+		}); err != nil {
+			return nil, err
+		}
+		report.Indexer = IndexerCreated
+		report.LastBlockHeight = lastBlockHeight
+	case err != nil:
+		return nil, err
+	case resetCursor:
+		// rows at or after the new cursor are indexed again, stale ones must not stay behind
+		if err := atomic(ctx, database, func(ctx context.Context, tx Database) error {
+			if err := deleteHeights(ctx, tx, uint32(lastBlockHeight), math.MaxUint32, HeightTables()); err != nil {
+				return err
+			}
+
+			return tx.UpdateFields(ctx, schema.IndexerTableName, "index_id", indexerUUid, map[string]any{
+				"last_block_height": lastBlockHeight,
+				"indexed_at":        time.Now(),
+			})
+		}); err != nil {
+			return nil, err
+		}
+		report.Indexer = IndexerCursorReset
+		report.PrevBlockHeight = indexer.LastBlockHeight
+		report.LastBlockHeight = lastBlockHeight
+	default:
+		report.Indexer = IndexerSkipped
+		report.PrevBlockHeight = indexer.LastBlockHeight
+		report.LastBlockHeight = indexer.LastBlockHeight
+	}
+
+	return report, nil
+}
+
+// CurrentVersion returns newest applied version, zero when nothing is applied.
+func CurrentVersion(status []MigrationStatus) uint {
+	var current uint
+	for _, s := range status {
+		if s.Applied && s.Version > current {
+			current = s.Version
+		}
+	}
+
+	return current
+}
+
+// downTarget returns version to migrate to for reverting n applied migrations.
+func downTarget(status []MigrationStatus, n uint) uint {
+	applied := make([]uint, 0, len(status))
+	for _, s := range status {
+		if s.Applied {
+			applied = append(applied, s.Version)
+		}
+	}
+
+	if n >= uint(len(applied)) {
+		return 0
+	}
+
+	return applied[uint(len(applied))-n-1]
+}
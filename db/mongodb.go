@@ -9,31 +9,72 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"strconv"
 	"time"
 )
 
+const _defaultMongoMaxConnecting = 500
+
 type Mongodb struct {
-	cli    *mongo.Client
-	db     *mongo.Database
-	engine schema.DatabaseEngine
-	dbType schema.DatabaseType
-	uri    string
-	name   string
+	cli      *mongo.Client
+	db       *mongo.Database
+	engine   schema.DatabaseEngine
+	dbType   schema.DatabaseType
+	uri      string
+	name     string
+	timeouts timeouts
 }
 
 func NewMongodb(ctx context.Context, db *schema.DB) (Database, error) {
 	mongodb := new(Mongodb)
+	mongodb.timeouts = newTimeouts(db)
+
+	opts := options.Client().ApplyURI(db.URI).
+		SetConnectTimeout(mongodb.timeouts.connect).
+		SetMaxConnecting(_defaultMongoMaxConnecting)
+
+	if db.MaxOpenConns != 0 {
+		opts.SetMaxPoolSize(uint64(db.MaxOpenConns)).
+			SetMaxConnecting(uint64(min(db.MaxOpenConns, _defaultMongoMaxConnecting)))
+	}
+
+	if len(db.WriteConcern) != 0 {
+		wc := writeconcern.Majority()
+		if db.WriteConcern != "majority" {
+			w, err := strconv.Atoi(db.WriteConcern)
+			if err != nil {
+				return nil, fmt.Errorf("mongodb: invalid write concern %s", db.WriteConcern)
+			}
+			wc = &writeconcern.WriteConcern{W: w}
+		}
+		opts.SetWriteConcern(wc)
+	}
+
+	if len(db.ReadPreference) != 0 {
+		mode, err := readpref.ModeFromString(db.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetReadPreference(rp)
+	}
 
-	opts := make([]*options.ClientOptions, 0)
-	opts = append(opts, options.Client().ApplyURI(db.URI))
-	opts = append(opts, options.Client().SetConnectTimeout(2*time.Second))
-	opts = append(opts, options.Client().SetMaxConnecting(500))
-	cli, err := mongo.Connect(ctx, opts...)
+	cli, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	if err := cli.Ping(ctx, nil); err != nil {
-		return nil, errors.Join(errors.New("mongodb: can't verify client connection "), err)
+
+	pingCtx, cancel := mongodb.timeouts.connectCtx(ctx)
+	defer cancel()
+	if err := cli.Ping(pingCtx, nil); err != nil {
+		return nil, errors.Join(errors.New("mongodb: can't verify client connection "), err,
+			cli.Disconnect(context.Background()))
 	}
 
 	mongodb.cli = cli
@@ -50,9 +91,17 @@ func (m *Mongodb) Close() error {
 	return m.cli.Disconnect(context.Background())
 }
 
-func (m *Mongodb) Migrate(ctx context.Context, indexerUUid string, lastBlockHeight int) error {
+func (m *Mongodb) Ping(ctx context.Context) error {
+	ctx, cancel := m.timeouts.connectCtx(ctx)
+	defer cancel()
+
+	return m.cli.Ping(ctx, nil)
+}
+
+func (m *Mongodb) migrations(ctx context.Context) *migrate.Migrate {
 	migration := migrate.NewMigrate(m.db, migrate.Migration{
-		Version: 1,
+		Version:     uint64(Migrations[0].Version),
+		Description: Migrations[0].Description,
 		Up: func(db *mongo.Database) error {
 			block := db.Collection(schema.BlockTableName)
 			transaction := db.Collection(schema.TransactionsTableName)
@@ -117,23 +166,103 @@ func (m *Mongodb) Migrate(ctx context.Context, indexerUUid string, lastBlockHeig
 			}
 
 			// indexer
-			if err := addUniqueIndex(ctx, indexer, "index_id", false); err != nil {
+			return addUniqueIndex(ctx, indexer, "index_id", false)
+		},
+		Down: func(db *mongo.Database) error {
+			return dropCollections(ctx, db, schema.BlockTableName, schema.TransactionsTableName,
+				schema.IndexerTableName)
+		},
+	}, migrate.Migration{
+		Version:     uint64(Migrations[1].Version),
+		Description: Migrations[1].Description,
+		Up: func(db *mongo.Database) error {
+			outbox := db.Collection(schema.WebhookOutboxTableName)
+
+			if err := addUniqueIndex(ctx, outbox, "event_id", false); err != nil {
 				return err
 			}
 
-			if _, err := indexer.InsertOne(ctx, &schema.Indexer{
-				IndexId:         indexerUUid,
-				LastBlockHeight: lastBlockHeight,
-				IndexedAt:       time.Now(),
-			}); err != nil {
+			if err := addNormalIndex(ctx, outbox, "status"); err != nil {
 				return err
 			}
 
-			return nil
+			return addNormalIndex(ctx, outbox, "block_height")
+		},
+		Down: func(db *mongo.Database) error {
+			return dropCollections(ctx, db, schema.WebhookOutboxTableName)
 		},
 	})
+	migration.SetMigrationsCollection(schema.MigrationsTableName)
+
+	return migration
+}
+
+func (m *Mongodb) Migrate(ctx context.Context) error {
+	return m.MigrateTo(ctx, LatestMigration())
+}
+
+func (m *Mongodb) MigrateTo(ctx context.Context, version uint) error {
+	if version > LatestMigration() {
+		return fmt.Errorf("unknown migration version %d, latest is %d", version, LatestMigration())
+	}
+
+	migration := m.migrations(ctx)
+	current, _, err := migration.Version()
+	if err != nil {
+		return err
+	}
+
+	// mongo-migrate counts steps, not versions
+	steps := 0
+	for _, v := range Migrations {
+		if (v.Version > uint(current) && v.Version <= version) || (v.Version <= uint(current) && v.Version > version) {
+			steps++
+		}
+	}
+
+	switch {
+	case steps == 0:
+		return nil
+	case version > uint(current):
+		return migration.Up(steps)
+	default:
+		return migration.Down(steps)
+	}
+}
+
+func (m *Mongodb) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	current, _, err := m.migrations(ctx).Version()
+	if err != nil {
+		return nil, err
+	}
 
-	return migration.Up(1)
+	// every up and down adds a record, the last record of a version is when it was applied
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := m.db.Collection(schema.MigrationsTableName).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]struct {
+		Version   uint64    `bson:"version"`
+		Timestamp time.Time `bson:"timestamp"`
+	}, 0)
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(Migrations))
+	for _, v := range Migrations {
+		st := MigrationStatus{MigrationVersion: v, Applied: v.Version <= uint(current)}
+		for _, r := range records {
+			if st.Applied && uint(r.Version) == v.Version {
+				st.AppliedAt = r.Timestamp
+			}
+		}
+		status = append(status, st)
+	}
+
+	return status, nil
 }
 
 func (m *Mongodb) Name() string {
@@ -149,12 +278,120 @@ func (m *Mongodb) Engine() string {
 }
 
 func (m *Mongodb) FindOne(ctx context.Context, tableOrCollectionName string, key string, val any, resultPtr any) error {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
 	col := m.db.Collection(tableOrCollectionName)
 
 	return col.FindOne(ctx, bson.M{key: val}).Decode(resultPtr)
 }
 
+func (m *Mongodb) FindMany(ctx context.Context, tableOrCollectionName string, key string, val any,
+	resultSlicePtr any) error {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	col := m.db.Collection(tableOrCollectionName)
+
+	cur, err := col.Find(ctx, bson.M{key: val})
+	if err != nil {
+		return err
+	}
+
+	return cur.All(ctx, resultSlicePtr)
+}
+
+// FindRange finds documents which key is between from and to inclusive, sorted by key.
+func (m *Mongodb) FindRange(ctx context.Context, tableOrCollectionName string, key string, from, to any,
+	resultSlicePtr any) error {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	col := m.db.Collection(tableOrCollectionName)
+
+	opts := options.Find().SetSort(bson.D{{Key: key, Value: 1}})
+
+	cur, err := col.Find(ctx, bson.M{key: bson.M{"$gte": from, "$lte": to}}, opts)
+	if err != nil {
+		return err
+	}
+
+	return cur.All(ctx, resultSlicePtr)
+}
+
+// FindPage finds at most limit documents matching key which orderKey is greater than after, sorted by orderKey.
+func (m *Mongodb) FindPage(ctx context.Context, tableOrCollectionName string, key string, val any, orderKey string,
+	after any, limit int, resultSlicePtr any) error {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	col := m.db.Collection(tableOrCollectionName)
+
+	opts := options.Find().SetSort(bson.D{{Key: orderKey, Value: 1}}).SetLimit(int64(limit))
+
+	cur, err := col.Find(ctx, bson.D{{Key: key, Value: val}, {Key: orderKey, Value: bson.M{"$gt": after}}}, opts)
+	if err != nil {
+		return err
+	}
+
+	return cur.All(ctx, resultSlicePtr)
+}
+
+// Count returns number of documents in collection.
+func (m *Mongodb) Count(ctx context.Context, tableOrCollectionName string) (int64, error) {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return m.db.Collection(tableOrCollectionName).CountDocuments(ctx, bson.M{})
+}
+
+// CountRange returns number of documents which key is between from and to inclusive.
+func (m *Mongodb) CountRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) (int64,
+	error) {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return m.db.Collection(tableOrCollectionName).CountDocuments(ctx, bson.M{key: bson.M{"$gte": from, "$lte": to}})
+}
+
+// SumRange returns sum of field of documents which key is between from and to inclusive.
+func (m *Mongodb) SumRange(ctx context.Context, tableOrCollectionName string, key string, from, to any,
+	field string) (int64, error) {
+	ctx, cancel := m.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	cur, err := m.db.Collection(tableOrCollectionName).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{key: bson.M{"$gte": from, "$lte": to}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "sum": bson.M{"$sum": "$" + field}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res := make([]struct {
+		Sum int64 `bson:"sum"`
+	}, 0, 1)
+	if err := cur.All(ctx, &res); err != nil || len(res) == 0 {
+		return 0, err
+	}
+
+	return res[0].Sum, nil
+}
+
+// DeleteRange deletes documents which key is between from and to inclusive.
+func (m *Mongodb) DeleteRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) error {
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	_, err := m.db.Collection(tableOrCollectionName).DeleteMany(ctx, bson.M{key: bson.M{"$gte": from, "$lte": to}})
+
+	return err
+}
+
 func (m *Mongodb) InsertOne(ctx context.Context, tableOrCollectionName string, dataPtr any) error {
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
 	col := m.db.Collection(tableOrCollectionName)
 
 	_, err := col.InsertOne(ctx, dataPtr)
@@ -162,6 +399,9 @@ func (m *Mongodb) InsertOne(ctx context.Context, tableOrCollectionName string, d
 }
 
 func (m *Mongodb) InsertMany(ctx context.Context, tableOrCollectionName string, dataPtr []any) error {
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
 	col := m.db.Collection(tableOrCollectionName)
 
 	_, err := col.InsertMany(ctx, dataPtr)
@@ -170,6 +410,9 @@ func (m *Mongodb) InsertMany(ctx context.Context, tableOrCollectionName string,
 
 func (m *Mongodb) UpdateOne(ctx context.Context, tableOrCollectionName string, key string, val any,
 	updKey string, updVal any) error {
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
 	col := m.db.Collection(tableOrCollectionName)
 
 	opts := options.Update().SetUpsert(false)
@@ -182,10 +425,83 @@ func (m *Mongodb) UpdateOne(ctx context.Context, tableOrCollectionName string, k
 	return nil
 }
 
+func (m *Mongodb) UpdateFields(ctx context.Context, tableOrCollectionName string, key string, val any,
+	fields map[string]any) error {
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	col := m.db.Collection(tableOrCollectionName)
+
+	opts := options.Update().SetUpsert(false)
+
+	_, err := col.UpdateOne(ctx, bson.M{key: val}, bson.M{"$set": bson.M(fields)}, opts)
+	return err
+}
+
+// UpsertMany replaces documents with the same key in one ordered bulk write, missing ones are inserted.
+func (m *Mongodb) UpsertMany(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(dataPtr))
+	for _, d := range dataPtr {
+		doc, err := bson.Marshal(d)
+		if err != nil {
+			return err
+		}
+
+		val, err := bson.Raw(doc).LookupErr(key)
+		if err != nil {
+			return fmt.Errorf("mongodb: %s of %T: %w", key, d, err)
+		}
+
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: key, Value: val}}).
+			SetReplacement(bson.Raw(doc)).SetUpsert(true))
+	}
+
+	_, err := m.db.Collection(tableOrCollectionName).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+
+	return err
+}
+
+// InsertNew inserts documents which key isn't stored yet in one ordered bulk write, stored ones are kept.
+func (m *Mongodb) InsertNew(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	ctx, cancel := m.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(dataPtr))
+	for _, d := range dataPtr {
+		doc, err := bson.Marshal(d)
+		if err != nil {
+			return err
+		}
+
+		val, err := bson.Raw(doc).LookupErr(key)
+		if err != nil {
+			return fmt.Errorf("mongodb: %s of %T: %w", key, d, err)
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.D{{Key: key, Value: val}}).
+			SetUpdate(bson.D{{Key: "$setOnInsert", Value: bson.Raw(doc)}}).SetUpsert(true))
+	}
+
+	_, err := m.db.Collection(tableOrCollectionName).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+
+	return err
+}
+
 // addNormalIndex create normal index for migration
 func addNormalIndex(ctx context.Context, collection *mongo.Collection, field string) error {
 	opt := options.Index().SetName(fmt.Sprintf("%s_%s_normal", collection.Name(), field)).SetUnique(false)
-	keys := bson.D{{field, 1}}
+	keys := bson.D{{Key: field, Value: 1}}
 	model := mongo.IndexModel{Keys: keys, Options: opt}
 	_, err := collection.Indexes().CreateOne(ctx, model)
 	return err
@@ -197,8 +513,18 @@ func addUniqueIndex(ctx context.Context, collection *mongo.Collection, field str
 	if sparse {
 		opt = opt.SetSparse(true)
 	}
-	keys := bson.D{{field, 1}}
+	keys := bson.D{{Key: field, Value: 1}}
 	model := mongo.IndexModel{Keys: keys, Options: opt}
 	_, err := collection.Indexes().CreateOne(ctx, model)
 	return err
 }
+
+func dropCollections(ctx context.Context, db *mongo.Database, names ...string) error {
+	for _, name := range names {
+		if err := db.Collection(name).Drop(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
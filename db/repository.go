@@ -0,0 +1,343 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"golang.org/x/sync/errgroup"
+	"reflect"
+	"slices"
+)
+
+// SaveBlocks stores blocks in every database, a stored block of the same height is replaced.
+func (p *Pool) SaveBlocks(ctx context.Context, blocks ...*schema.Block) error {
+	rows := make([]any, 0, len(blocks))
+	for _, b := range blocks {
+		rows = append(rows, b)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return p.upsert(ctx, schema.BlockTableName, rows)
+}
+
+// SaveTransactions stores transactions in every database, a stored transaction of the same hash is replaced.
+func (p *Pool) SaveTransactions(ctx context.Context, txs ...*schema.Transaction) error {
+	rows := make([]any, 0, len(txs))
+	for _, tx := range txs {
+		rows = append(rows, tx)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return p.upsert(ctx, schema.TransactionsTableName, rows)
+}
+
+// UpdateCursor moves cursor of indexer past height in every database which isn't already past it, it's used as
+// the final checkpoint on shutdown so every database agrees on the last indexed block.
+func (p *Pool) UpdateCursor(ctx context.Context, indexerId string, height uint32) error {
+	gp, gpCtx := errgroup.WithContext(ctx)
+	p.goUpdateCursors(gpCtx, gp, p.behind(height), indexerId, height)
+
+	return gp.Wait()
+}
+
+// GetCursor returns next height to index by database name.
+func (p *Pool) GetCursor(ctx context.Context, indexerId string) (map[string]uint32, error) {
+	items := p.engines()
+	indexers := make([]schema.Indexer, len(items))
+
+	gp, gpCtx := errgroup.WithContext(ctx)
+
+	for i, item := range items {
+		gp.Go(func() error {
+			if err := item.FindOne(gpCtx, schema.IndexerTableName, "index_id", indexerId, &indexers[i]); err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	if err := gp.Wait(); err != nil {
+		return nil, err
+	}
+
+	cursors := make(map[string]uint32, len(items))
+	for i, item := range items {
+		cursors[item.Name()] = uint32(indexers[i].LastBlockHeight)
+		p.setCursor(item.Name(), cursors[item.Name()])
+	}
+
+	return cursors, nil
+}
+
+// uniqueKeys are columns rows of a table are upserted by.
+var uniqueKeys = map[string]string{
+	schema.BlockTableName:         "height",
+	schema.TransactionsTableName:  "hash",
+	schema.WebhookOutboxTableName: "event_id",
+}
+
+// heightKeys are columns which tie rows of a table to a block height, only these tables can be rebuilt by
+// height.
+var heightKeys = map[string]string{
+	schema.BlockTableName:        "height",
+	schema.TransactionsTableName: "block_height",
+}
+
+// HeightTables returns tables which can be deleted and rebuilt by block height.
+func HeightTables() []string {
+	return []string{schema.BlockTableName, schema.TransactionsTableName}
+}
+
+// ReplaceBlock stores block and txs in named databases, every database when no name is given, replacing block
+// of the same height, then deletes transactions of the height which aren't in txs. Both run in a transaction on
+// engines which support them, others keep stale transactions on a failure instead of losing the block. Cursors
+// don't move.
+func (p *Pool) ReplaceBlock(ctx context.Context, block *schema.Block, txs []*schema.Transaction,
+	names ...string) error {
+	keep := make(map[string]bool, len(txs))
+	for _, tx := range txs {
+		keep[tx.Hash] = true
+	}
+
+	return p.eachSelected(ctx, names, func(ctx context.Context, item Database) error {
+		return atomic(ctx, item, func(ctx context.Context, tx Database) error {
+			if err := upsertBlock(ctx, tx, block, txs); err != nil {
+				return err
+			}
+
+			stored := make([]*schema.Transaction, 0)
+			if err := tx.FindMany(ctx, schema.TransactionsTableName, "block_height", block.Height,
+				&stored); err != nil {
+				return err
+			}
+
+			for _, s := range stored {
+				if keep[s.Hash] {
+					continue
+				}
+
+				if err := tx.DeleteRange(ctx, schema.TransactionsTableName, "hash", s.Hash, s.Hash); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// DeleteHeights deletes rows of heights from..to in tables of named databases, every database when no name
+// is given. Cursors don't move.
+func (p *Pool) DeleteHeights(ctx context.Context, from, to uint32, tables []string, names ...string) error {
+	return p.eachSelected(ctx, names, func(ctx context.Context, item Database) error {
+		return deleteHeights(ctx, item, from, to, tables)
+	})
+}
+
+// RebuildHeights replaces heights from..to of tables in named databases, every database when no name is given,
+// with blocks and txs of the range. Rows are deleted and stored again in a transaction on engines which support
+// them, so a failure leaves the old rows. Cursors don't move.
+func (p *Pool) RebuildHeights(ctx context.Context, from, to uint32, blocks []*schema.Block,
+	txs []*schema.Transaction, tables []string, names ...string) error {
+	byHeight := make(map[uint32][]*schema.Transaction, len(blocks))
+	for _, tx := range txs {
+		byHeight[tx.BlockHeight] = append(byHeight[tx.BlockHeight], tx)
+	}
+
+	return p.eachSelected(ctx, names, func(ctx context.Context, item Database) error {
+		return atomic(ctx, item, func(ctx context.Context, tx Database) error {
+			if err := deleteHeights(ctx, tx, from, to, tables); err != nil {
+				return err
+			}
+
+			for _, block := range blocks {
+				if err := saveBlock(ctx, tx, block, byHeight[block.Height], tables); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// SaveBlock stores block and txs in tables of named databases, every database when no name is given. Cursors
+// don't move.
+func (p *Pool) SaveBlock(ctx context.Context, block *schema.Block, txs []*schema.Transaction, tables []string,
+	names ...string) error {
+	return p.eachSelected(ctx, names, func(ctx context.Context, item Database) error {
+		return saveBlock(ctx, item, block, txs, tables)
+	})
+}
+
+func (p *Pool) eachSelected(ctx context.Context, names []string,
+	fn func(ctx context.Context, item Database) error) error {
+	items, err := p.selected(names)
+	if err != nil {
+		return err
+	}
+
+	gp, gpCtx := errgroup.WithContext(ctx)
+
+	for _, item := range items {
+		gp.Go(func() error {
+			if err := fn(gpCtx, item); err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	return gp.Wait()
+}
+
+func deleteHeights(ctx context.Context, item Database, from, to uint32, tables []string) error {
+	for _, table := range tables {
+		key, ok := heightKeys[table]
+		if !ok {
+			return fmt.Errorf("table %s can't be deleted by height", table)
+		}
+
+		if err := item.DeleteRange(ctx, table, key, from, to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func saveBlock(ctx context.Context, item Database, block *schema.Block, txs []*schema.Transaction,
+	tables []string) error {
+	for _, table := range tables {
+		rows := make([]any, 0, len(txs))
+		switch table {
+		case schema.BlockTableName:
+			rows = append(rows, block)
+		case schema.TransactionsTableName:
+			for _, tx := range txs {
+				rows = append(rows, tx)
+			}
+		default:
+			return fmt.Errorf("table %s can't be saved by height", table)
+		}
+
+		if len(rows) == 0 {
+			continue
+		}
+
+		if err := item.InsertMany(ctx, table, copyRows(rows)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selected returns named databases, every registered database when no name is given.
+func (p *Pool) selected(names []string) ([]Database, error) {
+	items := p.engines()
+	if len(names) == 0 {
+		return items, nil
+	}
+
+	selected := make([]Database, 0, len(names))
+	for _, name := range names {
+		idx := slices.IndexFunc(items, func(item Database) bool { return item.Name() == name })
+		if idx == -1 {
+			return nil, fmt.Errorf("database %s is not registered", name)
+		}
+		selected = append(selected, items[idx])
+	}
+
+	return selected, nil
+}
+
+// Names returns name of every database in registration order, same order as GetIndexer.
+func (p *Pool) Names() []string {
+	items := p.engines()
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Name())
+	}
+
+	return names
+}
+
+// Count returns number of rows in table by database name.
+func (p *Pool) Count(ctx context.Context, table string) (map[string]int64, error) {
+	items := p.engines()
+	counts := make([]int64, len(items))
+
+	gp, gpCtx := errgroup.WithContext(ctx)
+
+	for i, item := range items {
+		gp.Go(func() error {
+			n, err := item.Count(gpCtx, table)
+			if err != nil {
+				return newErr(item.Name(), item.Engine(), item.Type(), err.Error())
+			}
+			counts[i] = n
+
+			return nil
+		})
+	}
+
+	if err := gp.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(items))
+	for i, item := range items {
+		result[item.Name()] = counts[i]
+	}
+
+	return result, nil
+}
+
+// tableOf returns table or collection of a model, data of unknown types is rejected rather than written to
+// a wrong table.
+func tableOf(data any) (string, error) {
+	switch data.(type) {
+	case *schema.Block:
+		return schema.BlockTableName, nil
+	case *schema.Transaction:
+		return schema.TransactionsTableName, nil
+	case *schema.Indexer:
+		return schema.IndexerTableName, nil
+	case *schema.WebhookOutbox:
+		return schema.WebhookOutboxTableName, nil
+	}
+
+	return "", fmt.Errorf("no table for %T", data)
+}
+
+// copyRow returns a pointer to a copy of the value dataPtr points to. Every engine gets its own copy, gorm sets
+// primary key on the inserted value.
+func copyRow(dataPtr any) any {
+	v := reflect.ValueOf(dataPtr)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return dataPtr
+	}
+
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(v.Elem())
+
+	return c.Interface()
+}
+
+func copyRows(data []any) []any {
+	copies := make([]any, 0, len(data))
+	for _, d := range data {
+		copies = append(copies, copyRow(d))
+	}
+
+	return copies
+}
@@ -0,0 +1,284 @@
+package db_test
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIndexerId = "8bc5d30c-1ccc-460c-adcc-508608b0c188"
+
+func TestPool_RepositoryRoutesModels(t *testing.T) {
+	ctx := context.Background()
+	engines := dbtest.Engines(t)
+
+	p := db.NewPool(dbtest.Logger(t))
+	for _, e := range engines {
+		p.RegisterEngine(e)
+	}
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if err := p.SaveBlocks(ctx,
+		&schema.Block{Height: 1, Hash: "block-1", TotalTransactions: 0},
+		&schema.Block{Height: 2, Hash: "block-2", TotalTransactions: 2},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveTransactions(ctx,
+		&schema.Transaction{Hash: "tx-1", BlockHeight: 2, Type: "transfer", Value: 5, CreatedAt: createdAt},
+		&schema.Transaction{Hash: "tx-2", BlockHeight: 2, Type: "bond", Value: 7, CreatedAt: createdAt},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.InsertOne(ctx, &schema.Indexer{IndexId: "second-indexer", LastBlockHeight: 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.InsertMany(ctx, []any{&schema.Block{Height: 3}, &schema.Transaction{Hash: "tx-3"}}); err == nil {
+		t.Fatal("expected error for mixed models")
+	}
+
+	if err := p.InsertOne(ctx, &struct{ Name string }{"unknown"}); err == nil {
+		t.Fatal("expected error for unknown model")
+	}
+
+	if err := p.UpdateCursor(ctx, testIndexerId, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range engines {
+		blocks := make([]*schema.Block, 0)
+		if err := e.FindRange(ctx, schema.BlockTableName, "height", 1, 2, &blocks); err != nil {
+			t.Fatalf("%s: %v", e.Name(), err)
+		}
+
+		if len(blocks) != 2 || blocks[0].Hash != "block-1" || blocks[1].TotalTransactions != 2 {
+			t.Fatalf("%s: unexpected blocks %+v", e.Name(), blocks)
+		}
+
+		txs := make([]*schema.Transaction, 0)
+		if err := e.FindMany(ctx, schema.TransactionsTableName, "block_height", 2, &txs); err != nil {
+			t.Fatalf("%s: %v", e.Name(), err)
+		}
+
+		if len(txs) != 2 || txs[0].Hash == txs[1].Hash || !txs[0].CreatedAt.Equal(createdAt) {
+			t.Fatalf("%s: unexpected transactions %+v", e.Name(), txs)
+		}
+
+		var indexer schema.Indexer
+		if err := e.FindOne(ctx, schema.IndexerTableName, "index_id", "second-indexer", &indexer); err != nil {
+			t.Fatalf("%s: indexer not in indexers table: %v", e.Name(), err)
+		}
+
+		if indexer.LastBlockHeight != 9 {
+			t.Fatalf("%s: unexpected indexer %+v", e.Name(), indexer)
+		}
+	}
+
+	cursors, err := p.GetCursor(ctx, testIndexerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cursors) != len(engines) {
+		t.Fatalf("expected cursor of %d databases, got %v", len(engines), cursors)
+	}
+
+	for name, c := range cursors {
+		if c != 3 {
+			t.Fatalf("%s: expected cursor 3, got %d", name, c)
+		}
+	}
+}
+
+func TestPool_Count(t *testing.T) {
+	ctx := context.Background()
+	engines := dbtest.Engines(t)
+
+	p := db.NewPool(dbtest.Logger(t))
+	for _, e := range engines {
+		p.RegisterEngine(e)
+	}
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveBlocks(ctx, &schema.Block{Height: 1, Hash: "block-1", TotalTransactions: 2},
+		&schema.Block{Height: 2, Hash: "block-2", TotalTransactions: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := p.Count(ctx, schema.BlockTableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs, err := p.Count(ctx, schema.TransactionsTableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range p.Names() {
+		if blocks[name] != 2 || txs[name] != 0 {
+			t.Fatalf("%s: unexpected counts, blocks %d transactions %d", name, blocks[name], txs[name])
+		}
+	}
+
+	for _, e := range engines {
+		n, err := e.CountRange(ctx, schema.BlockTableName, "height", 2, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum, err := e.SumRange(ctx, schema.BlockTableName, "height", 1, 2, "total_transactions")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		empty, err := e.SumRange(ctx, schema.BlockTableName, "height", 3, 5, "total_transactions")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 1 || sum != 5 || empty != 0 {
+			t.Fatalf("%s: unexpected range count %d, sum %d and empty sum %d", e.Name(), n, sum, empty)
+		}
+	}
+}
+
+func TestPool_ReplaceBlock(t *testing.T) {
+	ctx := context.Background()
+	engines := dbtest.Engines(t)
+
+	p := db.NewPool(dbtest.Logger(t))
+	for _, e := range engines {
+		p.RegisterEngine(e)
+	}
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveBlocks(ctx, &schema.Block{Height: 1, Hash: "block-1"},
+		&schema.Block{Height: 2, Hash: "fork-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveTransactions(ctx, &schema.Transaction{Hash: "fork-tx", BlockHeight: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.ReplaceBlock(ctx, &schema.Block{Height: 2, Hash: "block-2", TotalTransactions: 1},
+		[]*schema.Transaction{{Hash: "tx-2", BlockHeight: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.ReplaceBlock(ctx, &schema.Block{Height: 3}, nil, "unknown"); err == nil {
+		t.Fatal("expected error for unknown database")
+	}
+
+	for _, e := range engines {
+		blocks := make([]*schema.Block, 0)
+		if err := e.FindRange(ctx, schema.BlockTableName, "height", 1, 2, &blocks); err != nil {
+			t.Fatalf("%s: %v", e.Name(), err)
+		}
+
+		if len(blocks) != 2 || blocks[0].Hash != "block-1" || blocks[1].Hash != "block-2" {
+			t.Fatalf("%s: unexpected blocks %+v", e.Name(), blocks)
+		}
+
+		txs := make([]*schema.Transaction, 0)
+		if err := e.FindMany(ctx, schema.TransactionsTableName, "block_height", 2, &txs); err != nil {
+			t.Fatalf("%s: %v", e.Name(), err)
+		}
+
+		if len(txs) != 1 || txs[0].Hash != "tx-2" {
+			t.Fatalf("%s: unexpected transactions %+v", e.Name(), txs)
+		}
+	}
+}
+
+func TestPool_FindPage(t *testing.T) {
+	ctx := context.Background()
+	for _, e := range dbtest.Engines(t) {
+		if err := e.Migrate(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, id := range []string{"c", "a", "d", "b", "e"} {
+			status := schema.OutboxPending
+			if id == "d" {
+				status = schema.OutboxDelivered
+			}
+
+			if err := e.InsertOne(ctx, schema.WebhookOutboxTableName, &schema.WebhookOutbox{EventId: id,
+				BlockHeight: uint32(i), Status: status}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got := make([]string, 0)
+		for after := ""; ; {
+			rows := make([]*schema.WebhookOutbox, 0)
+			if err := e.FindPage(ctx, schema.WebhookOutboxTableName, "status", schema.OutboxPending, "event_id",
+				after, 2, &rows); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rows) == 0 {
+				break
+			}
+
+			for _, r := range rows {
+				got = append(got, r.EventId)
+			}
+			after = rows[len(rows)-1].EventId
+		}
+
+		if strings.Join(got, "") != "abce" {
+			t.Fatalf("%s: expected pending rows abce in order, got %v", e.Name(), got)
+		}
+	}
+}
+
+func TestPool_InsertNew(t *testing.T) {
+	ctx := context.Background()
+	for _, e := range dbtest.Engines(t) {
+		if err := e.Migrate(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := e.InsertOne(ctx, schema.WebhookOutboxTableName, &schema.WebhookOutbox{EventId: "a",
+			BlockHeight: 1, Status: schema.OutboxDelivered}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the stored row keeps its status, only the new one is inserted
+		if err := e.InsertNew(ctx, schema.WebhookOutboxTableName, "event_id", []any{
+			&schema.WebhookOutbox{EventId: "a", BlockHeight: 1, Status: schema.OutboxPending},
+			&schema.WebhookOutbox{EventId: "b", BlockHeight: 1, Status: schema.OutboxPending},
+		}); err != nil {
+			t.Fatalf("%s: %v", e.Name(), err)
+		}
+
+		rows := make([]*schema.WebhookOutbox, 0)
+		if err := e.FindRange(ctx, schema.WebhookOutboxTableName, "event_id", "a", "b", &rows); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 2 || rows[0].Status != schema.OutboxDelivered || rows[1].Status != schema.OutboxPending {
+			t.Fatalf("%s: unexpected rows %+v", e.Name(), rows)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+)
+
+// Sink is a write only destination of indexed data, unlike Database it has no schema or query support,
+// so it can stream the chain into files or external systems.
+type Sink interface {
+	Name() string
+	// WriteBlock writes block and its transactions, data isn't durable until Checkpoint.
+	WriteBlock(ctx context.Context, block *schema.Block, txs []*schema.Transaction) error
+	// Rollback discards blocks written after height, height can't be lower than last checkpoint.
+	Rollback(ctx context.Context, height uint32) error
+	// Checkpoint makes everything written up to height durable.
+	Checkpoint(ctx context.Context, height uint32) error
+	// Cursor returns last checkpointed height, zero means nothing written yet.
+	Cursor(ctx context.Context) (uint32, error)
+	Close() error
+}
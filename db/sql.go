@@ -3,47 +3,88 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 type SQL struct {
-	db     *gorm.DB
-	engine schema.DatabaseEngine
-	dbType schema.DatabaseType
-	uri    string
-	name   string
+	db       *gorm.DB
+	engine   schema.DatabaseEngine
+	dbType   schema.DatabaseType
+	uri      string
+	name     string
+	timeouts timeouts
 }
 
-func NewSQL(dbCfg *schema.DB) (Database, error) {
+func NewSQL(ctx context.Context, dbCfg *schema.DB) (Database, error) {
 	sql := new(SQL)
 
+	gormCfg := &gorm.Config{
+		PrepareStmt:            true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	}
+
+	var dialector gorm.Dialector
 	switch dbCfg.Engine {
 	case schema.MYSQL, schema.MARIADB:
-		db, err := gorm.Open(mysql.Open(dbCfg.URI), &gorm.Config{
-			PrepareStmt:            true,
-			SkipDefaultTransaction: true,
-		})
-		if err != nil {
-			return nil, err
-		}
-		sql.db = db
+		dialector = mysql.Open(dbCfg.URI)
 	case schema.POSTGRESQL:
-		db, err := gorm.Open(postgres.Open(dbCfg.URI), &gorm.Config{
-			PrepareStmt:            true,
-			SkipDefaultTransaction: true,
-		})
-		if err != nil {
-			return nil, err
+		dialector = postgres.Open(dbCfg.URI)
+	case schema.SQLITE:
+		// sqlite creates the file but not its directory, like ./data of the default config
+		if dir := SQLiteDir(dbCfg.URI); len(dir) != 0 {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, err
+			}
 		}
-		sql.db = db
+		dialector = sqlite.Open(dbCfg.URI)
 	default:
 		return nil, errors.New("database engine is invalid")
 	}
 
+	db, err := gorm.Open(dialector, gormCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sdb, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows a single writer, more connections only end with database is locked
+	if dbCfg.Engine == schema.SQLITE {
+		sdb.SetMaxOpenConns(1)
+	}
+	if dbCfg.MaxOpenConns != 0 {
+		sdb.SetMaxOpenConns(dbCfg.MaxOpenConns)
+	}
+	if dbCfg.MaxIdleConns != 0 {
+		sdb.SetMaxIdleConns(dbCfg.MaxIdleConns)
+	}
+	if dbCfg.ConnMaxLifetime != 0 {
+		sdb.SetConnMaxLifetime(time.Duration(dbCfg.ConnMaxLifetime) * time.Second)
+	}
+
+	sql.timeouts = newTimeouts(dbCfg)
+
+	pingCtx, cancel := sql.timeouts.connectCtx(ctx)
+	defer cancel()
+	if err := sdb.PingContext(pingCtx); err != nil {
+		return nil, errors.Join(fmt.Errorf("%s: can't verify connection", dbCfg.Engine), err, sdb.Close())
+	}
+
+	sql.db = db
 	sql.uri = dbCfg.URI
 	sql.engine = dbCfg.Engine
 	sql.dbType = dbCfg.Type
@@ -52,6 +93,23 @@ func NewSQL(dbCfg *schema.DB) (Database, error) {
 	return sql, nil
 }
 
+// SQLiteDir returns directory of sqlite database file uri, empty for in memory databases and the working
+// directory.
+func SQLiteDir(uri string) string {
+	path, query, _ := strings.Cut(strings.TrimPrefix(uri, "file:"), "?")
+	path = strings.TrimPrefix(path, "//")
+
+	if len(path) == 0 || path == ":memory:" || strings.Contains(query, "mode=memory") {
+		return ""
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		return dir
+	}
+
+	return ""
+}
+
 func (s *SQL) Close() error {
 	sdb, err := s.db.DB()
 	if err != nil {
@@ -61,20 +119,138 @@ func (s *SQL) Close() error {
 	return sdb.Close()
 }
 
-func (s *SQL) Migrate(ctx context.Context, indexerUUid string, lastBlockHeight int) error {
-	mg := s.db.WithContext(ctx)
-	err := mg.Migrator().AutoMigrate(&schema.Block{}, &schema.Transaction{}, &schema.Indexer{})
+func (s *SQL) Ping(ctx context.Context) error {
+	sdb, err := s.db.DB()
 	if err != nil {
 		return err
 	}
 
-	mg.Create(&schema.Indexer{
-		IndexId:         indexerUUid,
-		LastBlockHeight: lastBlockHeight,
-		IndexedAt:       time.Now(),
-	})
+	ctx, cancel := s.timeouts.connectCtx(ctx)
+	defer cancel()
+
+	return sdb.PingContext(ctx)
+}
+
+// sqlMigration creates or drops tables of a migration version, tables created by AutoMigrate of older
+// releases are adopted and get columns they miss. models are frozen at the version, see sql_models.go.
+type sqlMigration struct {
+	MigrationVersion
+	models []any
+}
+
+var sqlMigrations = []sqlMigration{
+	{MigrationVersion: Migrations[0], models: []any{&blockV1{}, &transactionV1{}, &indexerV1{}}},
+	{MigrationVersion: Migrations[1], models: []any{&webhookOutboxV2{}}},
+}
+
+func (s *SQL) Migrate(ctx context.Context) error {
+	return s.MigrateTo(ctx, LatestMigration())
+}
+
+func (s *SQL) MigrateTo(ctx context.Context, version uint) error {
+	if version > LatestMigration() {
+		return fmt.Errorf("unknown migration version %d, latest is %d", version, LatestMigration())
+	}
+
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range sqlMigrations {
+		if m.Version > version || status[i].Applied {
+			continue
+		}
+
+		if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, model := range m.models {
+				if tx.Migrator().HasTable(model) {
+					if err := addMissingColumns(tx, model); err != nil {
+						return err
+					}
+					continue
+				}
+
+				if err := tx.Migrator().CreateTable(model); err != nil {
+					return err
+				}
+			}
+
+			return tx.Table(schema.MigrationsTableName).Create(&schema.SchemaMigration{
+				Version:     m.Version,
+				Description: m.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		}); err != nil {
+			return fmt.Errorf("migration %d up: %w", m.Version, err)
+		}
+	}
+
+	for i := len(sqlMigrations) - 1; i >= 0; i-- {
+		m := sqlMigrations[i]
+		if m.Version <= version || !status[i].Applied {
+			continue
+		}
+
+		if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(m.models...); err != nil {
+				return err
+			}
+
+			return tx.Table(schema.MigrationsTableName).
+				Delete(&schema.SchemaMigration{}, "version = ?", m.Version).Error
+		}); err != nil {
+			return fmt.Errorf("migration %d down: %w", m.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// addMissingColumns adds columns of model missing in its existing table, existing columns are left as they are.
+func addMissingColumns(tx *gorm.DB, model any) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	for _, column := range stmt.Schema.DBNames {
+		if tx.Migrator().HasColumn(model, column) {
+			continue
+		}
+
+		if err := tx.Migrator().AddColumn(model, column); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	return mg.Error
+func (s *SQL) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	mg := s.db.WithContext(ctx)
+	if err := mg.Table(schema.MigrationsTableName).AutoMigrate(&schema.SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	applied := make([]schema.SchemaMigration, 0)
+	if err := mg.Table(schema.MigrationsTableName).Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(sqlMigrations))
+	for _, m := range sqlMigrations {
+		st := MigrationStatus{MigrationVersion: m.MigrationVersion}
+		for _, a := range applied {
+			if a.Version == m.Version {
+				st.Applied = true
+				st.AppliedAt = a.AppliedAt
+			}
+		}
+		status = append(status, st)
+	}
+
+	return status, nil
 }
 
 func (s *SQL) Name() string {
@@ -90,37 +266,199 @@ func (s *SQL) Engine() string {
 }
 
 func (s *SQL) FindOne(ctx context.Context, tableOrCollectionName string, key string, val any, resultPtr any) error {
-	tb := s.db.Table(tableOrCollectionName)
-	tb.WithContext(ctx)
-	tb.Where(map[string]interface{}{key: val}).First(resultPtr)
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
 
-	return tb.Error
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(map[string]interface{}{key: val}).First(resultPtr).Error
 }
 
-func (s *SQL) InsertOne(ctx context.Context, tableOrCollectionName string, dataPtr any) error {
-	tb := s.db.Table(tableOrCollectionName)
-	tb.WithContext(ctx)
+func (s *SQL) FindMany(ctx context.Context, tableOrCollectionName string, key string, val any,
+	resultSlicePtr any) error {
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(map[string]interface{}{key: val}).Find(resultSlicePtr).Error
+}
+
+// FindRange finds rows which key is between from and to inclusive, ordered by key.
+func (s *SQL) FindRange(ctx context.Context, tableOrCollectionName string, key string, from, to any,
+	resultSlicePtr any) error {
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(s.db.Statement.Quote(key)+" BETWEEN ? AND ?", from, to).
+		Order(s.db.Statement.Quote(key)).Find(resultSlicePtr).Error
+}
+
+// FindPage finds at most limit rows matching key which orderKey is greater than after, ordered by orderKey.
+func (s *SQL) FindPage(ctx context.Context, tableOrCollectionName string, key string, val any, orderKey string,
+	after any, limit int, resultSlicePtr any) error {
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(map[string]interface{}{key: val}).
+		Where(s.db.Statement.Quote(orderKey)+" > ?", after).
+		Order(s.db.Statement.Quote(orderKey)).Limit(limit).Find(resultSlicePtr).Error
+}
 
-	tb.Create(dataPtr)
+// Count returns number of rows in table.
+func (s *SQL) Count(ctx context.Context, tableOrCollectionName string) (int64, error) {
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	var n int64
+	err := s.db.WithContext(ctx).Table(tableOrCollectionName).Count(&n).Error
+
+	return n, err
+}
+
+// CountRange returns number of rows which key is between from and to inclusive.
+func (s *SQL) CountRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) (int64,
+	error) {
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	var n int64
+	err := s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(s.db.Statement.Quote(key)+" BETWEEN ? AND ?", from, to).Count(&n).Error
+
+	return n, err
+}
+
+// SumRange returns sum of field of rows which key is between from and to inclusive.
+func (s *SQL) SumRange(ctx context.Context, tableOrCollectionName string, key string, from, to any,
+	field string) (int64, error) {
+	ctx, cancel := s.timeouts.queryCtx(ctx)
+	defer cancel()
+
+	var sum int64
+	err := s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Select("COALESCE(SUM("+s.db.Statement.Quote(field)+"), 0)").
+		Where(s.db.Statement.Quote(key)+" BETWEEN ? AND ?", from, to).Scan(&sum).Error
+
+	return sum, err
+}
+
+// DeleteRange deletes rows which key is between from and to inclusive.
+func (s *SQL) DeleteRange(ctx context.Context, tableOrCollectionName string, key string, from, to any) error {
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	return s.db.WithContext(ctx).Exec("DELETE FROM "+s.db.Statement.Quote(tableOrCollectionName)+
+		" WHERE "+s.db.Statement.Quote(key)+" BETWEEN ? AND ?", from, to).Error
+}
+
+func (s *SQL) InsertOne(ctx context.Context, tableOrCollectionName string, dataPtr any) error {
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
 
-	return tb.Error
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).Create(dataPtr).Error
 }
 
 func (s *SQL) InsertMany(ctx context.Context, tableOrCollectionName string, dataPtr []any) error {
-	tb := s.db.Table(tableOrCollectionName)
-	tb.WithContext(ctx)
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
 
-	tb.CreateInBatches(dataPtr, len(dataPtr))
+	// gorm can't reflect []any, so rows are created one by one in a single transaction
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, d := range dataPtr {
+			if err := tx.Table(tableOrCollectionName).Create(d).Error; err != nil {
+				return err
+			}
+		}
 
-	return tb.Error
+		return nil
+	})
 }
 
 func (s *SQL) UpdateOne(ctx context.Context, tableOrCollectionName string, key string, val any,
 	updKey string, updVal any) error {
-	tb := s.db.Table(tableOrCollectionName)
-	tb.WithContext(ctx)
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(map[string]interface{}{key: val}).Update(updKey, updVal).Error
+}
+
+func (s *SQL) UpdateFields(ctx context.Context, tableOrCollectionName string, key string, val any,
+	fields map[string]any) error {
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	return s.db.WithContext(ctx).Table(tableOrCollectionName).
+		Where(map[string]interface{}{key: val}).Updates(fields).Error
+}
+
+// UpsertMany creates rows in a single transaction, a row conflicting on key updates every column of the stored
+// one except its primary key.
+func (s *SQL) UpsertMany(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
 
-	tb.Where(map[string]interface{}{key: val}).Update(updKey, updVal)
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(dataPtr[0]); err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		if f := stmt.Schema.LookUpField(name); f != nil && !f.PrimaryKey {
+			columns = append(columns, name)
+		}
+	}
+
+	upsert := clause.OnConflict{
+		Columns:   []clause.Column{{Name: key}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}
 
-	return tb.Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, d := range dataPtr {
+			if err := tx.Table(tableOrCollectionName).Clauses(upsert).Create(d).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// InsertNew creates rows in a single transaction, a row conflicting on key is skipped.
+func (s *SQL) InsertNew(ctx context.Context, tableOrCollectionName string, key string, dataPtr []any) error {
+	if len(dataPtr) == 0 {
+		return nil
+	}
+
+	ctx, cancel := s.timeouts.writeCtx(ctx)
+	defer cancel()
+
+	skip := clause.OnConflict{Columns: []clause.Column{{Name: key}}, DoNothing: true}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, d := range dataPtr {
+			if err := tx.Table(tableOrCollectionName).Clauses(skip).Create(d).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Transaction runs fn with a copy of s which uses a single database transaction.
+func (s *SQL) Transaction(ctx context.Context, fn func(ctx context.Context, tx Database) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		c := *s
+		c.db = tx
+
+		return fn(ctx, &c)
+	})
 }
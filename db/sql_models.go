@@ -0,0 +1,72 @@
+package db
+
+import (
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"time"
+)
+
+// Tables created by sql migrations are frozen copies of schema models at their version, so changing a model
+// later doesn't change what an old migration creates or drops. A new column is a new migration with a new
+// copy.
+
+type blockV1 struct {
+	ID                uint    `gorm:"primarykey"`
+	Height            uint32  `gorm:"column:height;uniqueIndex"`
+	Hash              string  `gorm:"column:hash;uniqueIndex;size:100"`
+	TotalTransactions uint    `gorm:"column:total_transactions"`
+	BlockTime         uint32  `gorm:"column:block_time;index"`
+	BlockReward       int64   `gorm:"column:block_reward"`
+	Version           int32   `gorm:"column:version"`
+	PrevBlockHash     string  `gorm:"column:prev_block_hash;index"`
+	StateRoot         string  `gorm:"column:state_root"`
+	SortitionSeed     string  `gorm:"column:sortition_seed"`
+	ProposerAddress   string  `gorm:"column:proposer_address;index"`
+	CertificateHash   string  `gorm:"column:certificate_hash;index"`
+	Round             int32   `gorm:"column:round"`
+	Committers        []int32 `gorm:"serializer:json"`
+	Absentees         []int32 `gorm:"serializer:json"`
+	Signature         string  `gorm:"column:signature"`
+}
+
+func (blockV1) TableName() string { return schema.BlockTableName }
+
+type transactionV1 struct {
+	ID          uint      `gorm:"primarykey"`
+	Hash        string    `gorm:"column:hash;uniqueIndex;size:100"`
+	BlockHeight uint32    `gorm:"column:block_height;index"`
+	Version     int32     `gorm:"column:version"`
+	Type        string    `gorm:"column:type;index"`
+	From        string    `gorm:"column:from;index"`
+	To          string    `gorm:"column:to;index"`
+	Value       int64     `gorm:"column:value;index"`
+	Fee         int64     `gorm:"column:fee;index"`
+	Memo        string    `gorm:"column:memo"`
+	CreatedAt   time.Time `gorm:"column:created_at;index"`
+}
+
+func (transactionV1) TableName() string { return schema.TransactionsTableName }
+
+type indexerV1 struct {
+	ID              uint      `gorm:"primarykey"`
+	IndexId         string    `gorm:"column:index_id;uniqueIndex;size:36"`
+	LastBlockHeight int       `gorm:"column:last_block_height"`
+	IndexedAt       time.Time `gorm:"column:indexed_at"`
+}
+
+func (indexerV1) TableName() string { return schema.IndexerTableName }
+
+type webhookOutboxV2 struct {
+	ID            uint      `gorm:"primarykey"`
+	EventId       string    `gorm:"column:event_id;uniqueIndex;size:36"`
+	Webhook       string    `gorm:"column:webhook;index;size:100"`
+	Event         string    `gorm:"column:event;size:20"`
+	BlockHeight   uint32    `gorm:"column:block_height;index"`
+	Payload       string    `gorm:"column:payload;type:text"`
+	Status        string    `gorm:"column:status;index;size:20"`
+	Attempts      int       `gorm:"column:attempts"`
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;index"`
+	LastError     string    `gorm:"column:last_error;type:text"`
+	CreatedAt     time.Time `gorm:"column:created_at"`
+}
+
+func (webhookOutboxV2) TableName() string { return schema.WebhookOutboxTableName }
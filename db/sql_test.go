@@ -0,0 +1,377 @@
+package db
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"gorm.io/gorm"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testIndexerId = "8bc5d30c-1ccc-460c-adcc-508608b0c188"
+
+func setupSQLite(t *testing.T) Database {
+	t.Helper()
+	sql, err := NewSQL(context.Background(), &schema.DB{
+		Name:   "sqlite",
+		Type:   schema.SQL,
+		Engine: schema.SQLITE,
+		URI:    "file:" + filepath.Join(t.TempDir(), "indexer.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sql.Close() })
+
+	return sql
+}
+
+func setupLogger(t *testing.T) logging.Logger {
+	t.Helper()
+	logger, err := logging.New(logging.ConsoleHandler, logging.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return logger
+}
+
+func TestSQL_SQLitePipeline(t *testing.T) {
+	ctx := context.Background()
+	sql := setupSQLite(t)
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(sql)
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for h := uint32(1); h <= 3; h++ {
+		block := &schema.Block{Height: h, Hash: string(rune('a' + h)), Committers: []int32{1, 2}}
+		txs := []*schema.Transaction{{Hash: string(rune('k' + h)), BlockHeight: h, Type: "transfer", Value: 10}}
+
+		if err := p.WriteBlock(ctx, testIndexerId, block, txs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	indexers, err := p.GetIndexer(ctx, testIndexerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(indexers) != 1 || indexers[0].LastBlockHeight != 4 {
+		t.Fatalf("expected cursor 4, got %v", indexers)
+	}
+
+	blocks := make([]*schema.Block, 0)
+	if err := sql.FindRange(ctx, schema.BlockTableName, "height", 2, 3, &blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 2 || blocks[0].Height != 2 || len(blocks[1].Committers) != 2 {
+		t.Fatalf("unexpected blocks %v", blocks)
+	}
+
+	txs := make([]*schema.Transaction, 0)
+	if err := sql.FindMany(ctx, schema.TransactionsTableName, "type", "transfer", &txs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txs) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(txs))
+	}
+
+	// a height behind the cursor is skipped
+	if err := p.WriteBlock(ctx, testIndexerId, &schema.Block{Height: 3, Hash: "x"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var block schema.Block
+	if err := sql.FindOne(ctx, schema.BlockTableName, "height", 3, &block); err != nil || block.Hash != "d" {
+		t.Fatalf("expected block 3 unchanged, got %+v, %v", block, err)
+	}
+
+	// rows left by a write which failed before the cursor moved are replaced
+	if err := sql.InsertMany(ctx, schema.BlockTableName, []any{&schema.Block{Height: 4, Hash: "y"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.WriteBlock(ctx, testIndexerId, &schema.Block{Height: 4, Hash: "z"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var replaced schema.Block
+	if err := sql.FindOne(ctx, schema.BlockTableName, "height", 4, &replaced); err != nil || replaced.Hash != "z" {
+		t.Fatalf("expected block 4 replaced, got %+v, %v", replaced, err)
+	}
+
+	blocks = make([]*schema.Block, 0)
+	if err := sql.FindRange(ctx, schema.BlockTableName, "height", 1, 4, &blocks); err != nil || len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks, got %d, %v", len(blocks), err)
+	}
+}
+
+func TestSQL_VersionedMigrations(t *testing.T) {
+	ctx := context.Background()
+	sql := setupSQLite(t)
+	m := sql.(Migrator)
+
+	if err := sql.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := m.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if CurrentVersion(status) != LatestMigration() || status[0].AppliedAt.IsZero() {
+		t.Fatalf("expected all migrations applied, got %+v", status)
+	}
+
+	if err := m.MigrateTo(ctx, downTarget(status, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if sql.(*SQL).db.Migrator().HasTable(schema.WebhookOutboxTableName) {
+		t.Fatal("webhook outbox must be dropped")
+	}
+
+	if !sql.(*SQL).db.Migrator().HasTable(schema.BlockTableName) {
+		t.Fatal("blocks must be kept")
+	}
+
+	if err := m.MigrateTo(ctx, LatestMigration()); err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ = m.MigrationStatus(ctx)
+	if CurrentVersion(status) != LatestMigration() {
+		t.Fatalf("expected latest version, got %+v", status)
+	}
+
+	if err := m.MigrateTo(ctx, LatestMigration()+1); err == nil {
+		t.Fatal("expected unknown version error")
+	}
+}
+
+func TestSQL_MigrationKeepsCursor(t *testing.T) {
+	ctx := context.Background()
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(setupSQLite(t))
+
+	reports, err := p.Migration(ctx, testIndexerId, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reports[0].Indexer != IndexerCreated || len(reports[0].Applied) != len(Migrations) {
+		t.Fatalf("unexpected first report %+v", reports[0])
+	}
+
+	if err := p.WriteBlock(ctx, testIndexerId, &schema.Block{Height: 1, Hash: "a"},
+		[]*schema.Transaction{{Hash: "t", BlockHeight: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err = p.Migration(ctx, testIndexerId, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reports[0].Indexer != IndexerSkipped || len(reports[0].Applied) != 0 || reports[0].LastBlockHeight != 2 {
+		t.Fatalf("expected skipped indexer with cursor 2, got %+v", reports[0])
+	}
+
+	reports, err = p.Migration(ctx, testIndexerId, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reports[0].Indexer != IndexerCursorReset || reports[0].PrevBlockHeight != 2 {
+		t.Fatalf("expected cursor reset from 2, got %+v", reports[0])
+	}
+
+	indexers, err := p.GetIndexer(ctx, testIndexerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if indexers[0].LastBlockHeight != 1 {
+		t.Fatalf("expected cursor 1, got %d", indexers[0].LastBlockHeight)
+	}
+
+	// block 1 is indexed again after the reset, so it's deleted with its transactions
+	for _, table := range HeightTables() {
+		counts, err := p.Count(ctx, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if counts["sqlite"] != 0 {
+			t.Fatalf("expected no rows left in %s, got %d", table, counts["sqlite"])
+		}
+	}
+}
+
+func TestSQL_RebuildHeightsRollsBack(t *testing.T) {
+	ctx := context.Background()
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(setupSQLite(t))
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveBlocks(ctx, &schema.Block{Height: 1, Hash: "a"}, &schema.Block{Height: 2, Hash: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second block repeats a hash, so the batch fails after its heights are deleted
+	err := p.RebuildHeights(ctx, 1, 2, []*schema.Block{{Height: 1, Hash: "c"}, {Height: 2, Hash: "c"}}, nil,
+		[]string{schema.BlockTableName})
+	if err == nil {
+		t.Fatal("expected duplicate hash error")
+	}
+
+	counts, err := p.Count(ctx, schema.BlockTableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if counts["sqlite"] != 2 {
+		t.Fatalf("expected old blocks kept, got %d", counts["sqlite"])
+	}
+}
+
+func TestSQL_SQLiteCreatesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data", "nested")
+	sql, err := NewSQL(context.Background(), &schema.DB{Name: "sqlite", Type: schema.SQL, Engine: schema.SQLITE,
+		URI: "file:" + filepath.Join(dir, "indexer.db") + "?_pragma=journal_mode(WAL)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sql.Close() })
+
+	if err := sql.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for uri, want := range map[string]string{
+		"file:indexer.db":                    "",
+		"file::memory:?cache=shared":         "",
+		"file:test.db?mode=memory":           "",
+		"file:./data/indexer.db?_pragma=x":   "data",
+		"file:///var/lib/indexer/indexer.db": "/var/lib/indexer",
+	} {
+		if got := SQLiteDir(uri); got != want {
+			t.Fatalf("%s: expected directory %q, got %q", uri, want, got)
+		}
+	}
+}
+
+func TestSQL_ReplaceBlockKeepsRowsOnFailure(t *testing.T) {
+	ctx := context.Background()
+	p := NewPool(setupLogger(t))
+	p.RegisterEngine(setupSQLite(t))
+
+	if _, err := p.Migration(ctx, testIndexerId, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveBlocks(ctx, &schema.Block{Height: 1, Hash: "a"}, &schema.Block{Height: 2, Hash: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SaveTransactions(ctx, &schema.Transaction{Hash: "t", BlockHeight: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// hash of block 1 can't be stored at height 2, the stored block and its transaction stay
+	if err := p.ReplaceBlock(ctx, &schema.Block{Height: 2, Hash: "a"}, nil); err == nil {
+		t.Fatal("expected duplicate hash error")
+	}
+
+	database, _ := p.Engine("sqlite")
+	block := new(schema.Block)
+	if err := database.FindOne(ctx, schema.BlockTableName, "height", 2, block); err != nil || block.Hash != "b" {
+		t.Fatalf("expected block 2 kept, got %+v, %v", block, err)
+	}
+
+	txs := make([]*schema.Transaction, 0)
+	if err := database.FindMany(ctx, schema.TransactionsTableName, "block_height", 2, &txs); err != nil ||
+		len(txs) != 1 {
+		t.Fatalf("expected transaction of block 2 kept, got %v, %v", txs, err)
+	}
+}
+
+// TestSQL_FrozenModels fails when a schema model gets a column no migration creates.
+func TestSQL_FrozenModels(t *testing.T) {
+	sql := setupSQLite(t).(*SQL)
+
+	columns := func(model any) []string {
+		stmt := &gorm.Statement{DB: sql.db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+
+		return stmt.Schema.DBNames
+	}
+
+	for model, frozen := range map[any]any{
+		&schema.Block{}:         &blockV1{},
+		&schema.Transaction{}:   &transactionV1{},
+		&schema.Indexer{}:       &indexerV1{},
+		&schema.WebhookOutbox{}: &webhookOutboxV2{},
+	} {
+		if got, want := columns(model), columns(frozen); !slices.Equal(got, want) {
+			t.Fatalf("%T has columns %v, latest migration creates %v", model, got, want)
+		}
+	}
+}
+
+// baselineTransaction is the transactions table created by AutoMigrate before the version column.
+type baselineTransaction struct {
+	ID          uint   `gorm:"primarykey"`
+	Hash        string `gorm:"column:hash;uniqueIndex;size:100"`
+	BlockHeight uint32 `gorm:"column:block_height;index"`
+	Type        string `gorm:"column:type;index"`
+}
+
+func (baselineTransaction) TableName() string { return schema.TransactionsTableName }
+
+func TestSQL_MigrationAddsMissingColumns(t *testing.T) {
+	ctx := context.Background()
+	sql := setupSQLite(t).(*SQL)
+
+	if err := sql.db.Migrator().CreateTable(&baselineTransaction{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sql.db.Create(&baselineTransaction{Hash: "t", BlockHeight: 1, Type: "transfer"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sql.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, column := range []string{"version", "from", "memo", "created_at"} {
+		if !sql.db.Migrator().HasColumn(&transactionV1{}, column) {
+			t.Fatalf("expected column %s added", column)
+		}
+	}
+
+	if err := sql.InsertOne(ctx, schema.TransactionsTableName, &schema.Transaction{Hash: "u", BlockHeight: 2,
+		Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var tx schema.Transaction
+	if err := sql.FindOne(ctx, schema.TransactionsTableName, "hash", "t", &tx); err != nil || tx.Type != "transfer" {
+		t.Fatalf("expected baseline row kept, got %+v, %v", tx, err)
+	}
+}
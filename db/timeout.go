@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"time"
+)
+
+const _defaultConnectTimeout = 2 * time.Second
+
+// timeouts bounds every read and write of an engine, zero means no timeout.
+type timeouts struct {
+	connect time.Duration
+	query   time.Duration
+	write   time.Duration
+}
+
+func newTimeouts(dbCfg *schema.DB) timeouts {
+	t := timeouts{
+		connect: time.Duration(dbCfg.ConnectTimeout) * time.Second,
+		query:   time.Duration(dbCfg.QueryTimeout) * time.Second,
+		write:   time.Duration(dbCfg.WriteTimeout) * time.Second,
+	}
+
+	if t.connect == 0 {
+		t.connect = _defaultConnectTimeout
+	}
+
+	return t
+}
+
+func (t timeouts) connectCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.connect)
+}
+
+func (t timeouts) queryCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.query)
+}
+
+func (t timeouts) writeCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.write)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d == 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, d)
+}
@@ -7,9 +7,11 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type (
@@ -34,6 +36,7 @@ const (
 )
 
 type Log struct {
+	mu         sync.RWMutex
 	skipCaller int
 	slog       *slog.Logger
 	sentry     *sentry.Client
@@ -82,11 +85,25 @@ type Logger interface {
 	GetSentryClient() *sentry.Client
 }
 
+// Reloader is implemented by loggers which can change handler and options while in use.
+type Reloader interface {
+	Reload(handler HandleType, loggerOption Options) error
+}
+
 func New(
 	handler HandleType,
 	loggerOption Options,
 ) (Logger, error) {
 	log := new(Log)
+	if err := log.Reload(handler, loggerOption); err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// Reload replaces handler and options of logger, records logged meanwhile use either old or new handler.
+func (l *Log) Reload(handler HandleType, loggerOption Options) error {
 	logger := slog.Default()
 	slogHandlerOpt := new(slog.HandlerOptions)
 	slogHandlerOpt.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
@@ -101,13 +118,13 @@ func New(
 		slogHandlerOpt.AddSource = true
 	}
 
+	var client *sentry.Client
 	if loggerOption.Sentry != nil {
-		client, err := sentry.NewClient(setSentryOptions(loggerOption.Sentry))
+		var err error
+		client, err = sentry.NewClient(setSentryOptions(loggerOption.Sentry))
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		log.sentry = client
 	}
 
 	switch handler {
@@ -129,10 +146,18 @@ func New(
 		))
 	}
 
-	log.slog = logger
-	log.skipCaller = loggerOption.SkipCaller
+	l.mu.Lock()
+	prev := l.sentry
+	l.slog = logger
+	l.sentry = client
+	l.skipCaller = loggerOption.SkipCaller
+	l.mu.Unlock()
 
-	return log, nil
+	if prev != nil {
+		prev.Flush(_defaultSentryFlushTimeout)
+	}
+
+	return nil
 }
 
 func (l *Log) Debug(toSentry bool, msg string, keyValues ...any) {
@@ -178,13 +203,21 @@ func (l *Log) FatalContext(ctx context.Context, toSentry bool, msg string, keyVa
 }
 
 func (l *Log) Log(ctx context.Context, toSentry bool, level slog.Level, msg string, keyValues ...any) {
+	l.mu.RLock()
+	logger, client, skipCaller := l.slog, l.sentry, l.skipCaller
+	l.mu.RUnlock()
+
 	var pcs [1]uintptr
-	runtime.Callers(l.skipCaller, pcs[:])
+	runtime.Callers(skipCaller, pcs[:])
 	rec := slog.NewRecord(time.Now(), level, msg, pcs[0])
 	rec.Add(keyValues...)
 
-	if toSentry && l.sentry != nil {
-		defer l.sentry.Flush(_defaultSentryFlushTimeout)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.Add("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+
+	if toSentry && client != nil {
+		defer client.Flush(_defaultSentryFlushTimeout)
 		sentryLevel := sentry.LevelInfo
 		switch level {
 		case slog.LevelWarn:
@@ -194,18 +227,21 @@ func (l *Log) Log(ctx context.Context, toSentry bool, level slog.Level, msg stri
 		case slog.LevelDebug:
 			sentryLevel = sentry.LevelDebug
 		}
-		event := l.sentry.EventFromMessage(fmt.Sprint(msg, keyValues), sentryLevel)
-		l.sentry.CaptureEvent(event, nil, nil)
+		event := client.EventFromMessage(fmt.Sprint(msg, keyValues), sentryLevel)
+		client.CaptureEvent(event, nil, nil)
 		rec.Add(
 			"sent_to_sentry", true,
 			"sentry_event_id", event.EventID,
 		)
 	}
 
-	_ = l.slog.Handler().Handle(ctx, rec)
+	_ = logger.Handler().Handle(ctx, rec)
 }
 
 func (l *Log) GetSentryClient() *sentry.Client {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	return l.sentry
 }
 
@@ -1,7 +1,11 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"strings"
 	"testing"
 )
 
@@ -59,3 +63,37 @@ func TestLog_WarnContext(t *testing.T) {
 	logger := setup(t)
 	logger.WarnContext(context.TODO(), false, "error example", "test", 2, "test2", 2.5)
 }
+
+func TestLog_Reload(t *testing.T) {
+	logger := setup(t)
+
+	r, ok := logger.(Reloader)
+	if !ok {
+		t.Fatal("logger is not a reloader")
+	}
+
+	if err := r.Reload(JSONHandler, Options{Debug: false, SkipCaller: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if logger.(*Log).slog.Enabled(context.TODO(), slog.LevelDebug) {
+		t.Fatal("debug level still enabled after reload")
+	}
+	logger.Info(false, "reloaded", "handler", "json")
+}
+
+func TestLog_TraceIds(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := &Log{slog: slog.New(slog.NewJSONHandler(buf, nil)), skipCaller: 3}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.TODO(), sc), false, "traced")
+
+	if !strings.Contains(buf.String(), `"trace_id":"`+sc.TraceID().String()+`"`) ||
+		!strings.Contains(buf.String(), `"span_id":"`+sc.SpanID().String()+`"`) {
+		t.Fatalf("trace ids not logged: %s", buf)
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const namespace = "indexer"
+
+// Registry holds every indexer metric with go and process collectors, it's served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	ChainTipHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_tip_height",
+		Help:      "Last block height of pactus node.",
+	})
+
+	IndexedHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "indexed_height",
+		Help:      "Last block height stored in database or sink.",
+	}, []string{"db"})
+
+	BlocksIndexed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_indexed_total",
+		Help:      "Blocks stored in every database and sink.",
+	})
+
+	TransactionsIndexed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_indexed_total",
+		Help:      "Transactions stored in every database and sink.",
+	})
+
+	GRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of pactus grpc calls, every retry is observed.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	GRPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_errors_total",
+		Help:      "Failed pactus grpc calls.",
+	}, []string{"method", "code"})
+
+	GapsRepaired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gaps_repaired_total",
+		Help:      "Heights fetched again by gap repair, reason is missing_block or transaction_mismatch.",
+	}, []string{"db", "reason"})
+
+	DBWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_write_duration_seconds",
+		Help:      "Latency of writes to databases.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"engine", "db"})
+)
+
+var (
+	lagBlocksDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "lag_blocks"),
+		"Blocks between chain tip and last indexed block.", []string{"db"}, nil)
+	lagSecondsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "lag_seconds"),
+		"Seconds since time of last indexed block.", []string{"db"}, nil)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ChainTipHeight, IndexedHeight, BlocksIndexed, TransactionsIndexed,
+		GRPCDuration, GRPCErrors, DBWriteDuration, GapsRepaired,
+		progress,
+	)
+}
+
+// Handler serves metrics of Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// SetTip records last block height of chain.
+func SetTip(height uint32) {
+	ChainTipHeight.Set(float64(height))
+	progress.setTip(height)
+}
+
+// SetIndexed records last block stored in a database or sink, zero blockTime means it's unknown.
+func SetIndexed(db string, height uint32, blockTime time.Time) {
+	IndexedHeight.WithLabelValues(db).Set(float64(height))
+	progress.setIndexed(db, height, blockTime)
+}
+
+// Behind is lag of a database.
+type Behind struct {
+	Blocks  uint32
+	Seconds float64
+}
+
+// Lag returns blocks and seconds behind chain tip of every database, seconds is zero when block time of
+// last indexed block is unknown.
+func Lag() map[string]Behind {
+	return progress.lag(time.Now())
+}
+
+// ObserveWrite records latency of a write started at start.
+func ObserveWrite(engine, db string, start time.Time) {
+	DBWriteDuration.WithLabelValues(engine, db).Observe(time.Since(start).Seconds())
+}
+
+// UnaryClientInterceptor records latency and errors of every grpc call by method.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		code := status.Code(err).String()
+		GRPCDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+		if err != nil {
+			GRPCErrors.WithLabelValues(method, code).Inc()
+		}
+
+		return err
+	}
+}
+
+type indexed struct {
+	height    uint32
+	blockTime time.Time
+}
+
+// tracker keeps tip and indexed heights, lag is computed on every scrape so it grows while indexer is stuck.
+type tracker struct {
+	mu  sync.Mutex
+	tip uint32
+	dbs map[string]indexed
+}
+
+var progress = &tracker{dbs: make(map[string]indexed)}
+
+func (t *tracker) setTip(height uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tip = height
+}
+
+func (t *tracker) setIndexed(db string, height uint32, blockTime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if blockTime.IsZero() {
+		blockTime = t.dbs[db].blockTime
+	}
+	t.dbs[db] = indexed{height: height, blockTime: blockTime}
+}
+
+func (t *tracker) lag(now time.Time) map[string]Behind {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lag := make(map[string]Behind, len(t.dbs))
+	for db, idx := range t.dbs {
+		b := Behind{}
+		if t.tip > idx.height {
+			b.Blocks = t.tip - idx.height
+		}
+		if !idx.blockTime.IsZero() {
+			b.Seconds = now.Sub(idx.blockTime).Seconds()
+		}
+		lag[db] = b
+	}
+
+	return lag
+}
+
+func (t *tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lagBlocksDesc
+	ch <- lagSecondsDesc
+}
+
+func (t *tracker) Collect(ch chan<- prometheus.Metric) {
+	for db, b := range t.lag(time.Now()) {
+		ch <- prometheus.MustNewConstMetric(lagBlocksDesc, prometheus.GaugeValue, float64(b.Blocks), db)
+		ch <- prometheus.MustNewConstMetric(lagSecondsDesc, prometheus.GaugeValue, b.Seconds, db)
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestTracker_Lag(t *testing.T) {
+	tr := &tracker{dbs: make(map[string]indexed)}
+	now := time.Unix(1000, 0)
+
+	tr.setTip(110)
+	tr.setIndexed("postgres", 100, now.Add(-30*time.Second))
+	tr.setIndexed("mongo", 110, time.Time{})
+
+	lag := tr.lag(now)
+	if lag["postgres"].Blocks != 10 || lag["postgres"].Seconds != 30 {
+		t.Fatalf("unexpected postgres lag %+v", lag["postgres"])
+	}
+
+	if lag["mongo"].Blocks != 0 || lag["mongo"].Seconds != 0 {
+		t.Fatalf("unexpected mongo lag %+v", lag["mongo"])
+	}
+
+	// unknown block time keeps the last known one
+	tr.setIndexed("postgres", 101, time.Time{})
+	if lag := tr.lag(now); lag["postgres"].Blocks != 9 || lag["postgres"].Seconds != 30 {
+		t.Fatalf("unexpected postgres lag %+v", lag["postgres"])
+	}
+}
+
+// TestUnaryClientInterceptor asserts deltas, collectors are global so other runs of the test already counted.
+func TestUnaryClientInterceptor(t *testing.T) {
+	const method = "/pactus.Blockchain/GetBlock"
+	interceptor := UnaryClientInterceptor()
+	code := codes.Unavailable.String()
+
+	samples := func() uint64 {
+		m := &dto.Metric{}
+		if err := GRPCDuration.WithLabelValues(method, code).(prometheus.Metric).Write(m); err != nil {
+			t.Fatal(err)
+		}
+
+		return m.GetHistogram().GetSampleCount()
+	}
+	errs, observed := testutil.ToFloat64(GRPCErrors.WithLabelValues(method, code)), samples()
+
+	failing := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "node is down")
+	}
+
+	if err := interceptor(context.Background(), method, nil, nil, nil, failing); err == nil {
+		t.Fatal("expected error of invoker")
+	}
+
+	if n := testutil.ToFloat64(GRPCErrors.WithLabelValues(method, code)) - errs; n != 1 {
+		t.Fatalf("expected 1 error, got %v", n)
+	}
+
+	if n := samples() - observed; n != 1 {
+		t.Fatalf("expected 1 latency sample, got %d", n)
+	}
+}
@@ -5,15 +5,25 @@ import (
 	"fmt"
 	"github.com/Pactus-Contrib/Indexer/logging"
 	"github.com/google/uuid"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
-	LastBlockHeight      int      `yaml:"last_block_height"`
-	SyncIntervalPerBlock int      `yaml:"sync_interval_per_block"`
-	IndexerUuid          string   `yaml:"indexer_uuid"`
-	Pactus               *Pactus  `yaml:"pactus"`
-	DBS                  []*DB    `yaml:"dbs"`
-	Logging              *Logging `yaml:"logging"`
+	LastBlockHeight      int        `yaml:"last_block_height"`
+	SyncIntervalPerBlock int        `yaml:"sync_interval_per_block"`
+	ShutdownTimeout      int        `yaml:"shutdown_timeout"` // ShutdownTimeout in seconds to drain writes on SIGTERM, default is 30
+	IndexerUuid          string     `yaml:"indexer_uuid"`
+	Pactus               *Pactus    `yaml:"pactus"`
+	DBS                  []*DB      `yaml:"dbs"`
+	Sinks                []*Sink    `yaml:"sinks"`
+	Logging              *Logging   `yaml:"logging"`
+	Webhooks             *Webhooks  `yaml:"webhooks"`
+	HTTP                 *HTTP      `yaml:"http"`
+	Tracing              *Tracing   `yaml:"tracing"`
+	GapRepair            *GapRepair `yaml:"gap_repair"`
 }
 
 type Pactus struct {
@@ -24,22 +34,111 @@ type DB struct {
 	Name     string         `yaml:"name"`
 	Type     DatabaseType   `yaml:"type"`
 	Engine   DatabaseEngine `yaml:"engine"`
-	URI      string         `yaml:"uri"`
+	URI      string         `yaml:"uri" secret:"true"`
 	Database string         `yaml:"database"`
+
+	MaxOpenConns    int    `yaml:"max_open_conns"`    // MaxOpenConns 0 is driver default, max pool size for mongodb
+	MaxIdleConns    int    `yaml:"max_idle_conns"`    // MaxIdleConns 0 is driver default, not used by mongodb
+	ConnMaxLifetime int    `yaml:"conn_max_lifetime"` // ConnMaxLifetime in seconds, not used by mongodb
+	ConnectTimeout  int    `yaml:"connect_timeout"`   // ConnectTimeout in seconds, default is 2
+	QueryTimeout    int    `yaml:"query_timeout"`     // QueryTimeout in seconds for every read, 0 is no timeout
+	WriteTimeout    int    `yaml:"write_timeout"`     // WriteTimeout in seconds for every write, 0 is no timeout
+	WriteConcern    string `yaml:"write_concern"`     // WriteConcern mongodb only, majority or number of nodes
+	ReadPreference  string `yaml:"read_preference"`   // ReadPreference mongodb only, primary, secondary, nearest, ...
+}
+
+type Sink struct {
+	Name        string   `yaml:"name"`
+	Type        SinkType `yaml:"type"`
+	Path        string   `yaml:"path"`         // Path file path for file sink, directory for ndjson, csv and parquet sinks
+	SegmentSize uint32   `yaml:"segment_size"` // SegmentSize heights per segment or parquet part file, default is 10000
 }
 
 type Logging struct {
 	Debug        bool               `yaml:"debug" json:"debug"`
 	Handler      logging.HandleType `yaml:"handler" json:"handler"` // Handler 0= console handler, 1= text handler, 2= json handler
 	EnableCaller bool               `yaml:"enable_caller" json:"enable_caller"`
-	SentryDSN    string             `yaml:"sentry_dsn" json:"sentry_dsn"`
+	SentryDSN    string             `yaml:"sentry_dsn" json:"sentry_dsn" secret:"true"`
+}
+
+type HTTP struct {
+	Listen      string `yaml:"listen"`        // Listen address of /metrics, /healthz and /readyz, e.g. :9090, empty disables it
+	ReadyMaxLag int    `yaml:"ready_max_lag"` // ReadyMaxLag blocks behind chain tip before /readyz fails, 0 disables lag check
+}
+
+type Tracing struct {
+	Exporter   TracingExporter `yaml:"exporter"`    // Exporter otlp or file
+	Endpoint   string          `yaml:"endpoint"`    // Endpoint host:port of otlp http collector, default is OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318
+	Insecure   bool            `yaml:"insecure"`    // Insecure sends spans to otlp collector without tls
+	Path       string          `yaml:"path"`        // Path of file exporter, spans are appended as json
+	SampleRate float64         `yaml:"sample_rate"` // SampleRate of traces between 0 and 1, 0 is 1
+}
+
+type GapRepair struct {
+	Interval  int `yaml:"interval"`   // Interval in seconds between scans of indexed heights, default is 300
+	BatchSize int `yaml:"batch_size"` // BatchSize heights read per query while scanning, default is 1000
+	// FullScanInterval in seconds between scans from start height, default is 86400
+	FullScanInterval int `yaml:"full_scan_interval"`
+}
+
+type Webhooks struct {
+	OutboxDB string     `yaml:"outbox_db"` // OutboxDB name of database keeps webhook outbox, default is first database
+	Targets  []*Webhook `yaml:"targets"`
+}
+
+type Webhook struct {
+	Name       string         `yaml:"name"`
+	URL        string         `yaml:"url"`
+	Secret     string         `yaml:"secret" secret:"true"` // Secret sign payload with HMAC-SHA256
+	Event      WebhookEvent   `yaml:"event"`                // Event block or transaction
+	Filter     *WebhookFilter `yaml:"filter"`               // Filter only for transaction event
+	MaxRetries int            `yaml:"max_retries"`          // MaxRetries default is 8, -1 disables retries
+	Timeout    int            `yaml:"timeout"`              // Timeout request timeout in seconds, default is 10
+}
+
+type WebhookFilter struct {
+	Types     []string `yaml:"types"`     // Types transfer, bond, sortition, unbond, withdraw
+	Addresses []string `yaml:"addresses"` // Addresses match sender or receiver
+	MinValue  int64    `yaml:"min_value"`
 }
 
 type (
-	DatabaseType   string
-	DatabaseEngine string
+	DatabaseType    string
+	DatabaseEngine  string
+	WebhookEvent    string
+	SinkType        string
+	TracingExporter string
 )
 
+const (
+	OTLPExporter TracingExporter = "otlp"
+	FileExporter TracingExporter = "file"
+)
+
+func (t TracingExporter) String() string {
+	return string(t)
+}
+
+const (
+	FILE    SinkType = "file"
+	NDJSON  SinkType = "ndjson"
+	CSV     SinkType = "csv"
+	PARQUET SinkType = "parquet"
+)
+
+func (s SinkType) String() string {
+	return string(s)
+}
+
+const (
+	BlockEvent       WebhookEvent = "block"
+	TransactionEvent WebhookEvent = "transaction"
+)
+
+func (w WebhookEvent) String() string {
+	return string(w)
+}
+
 const (
 	SQL   DatabaseType = "sql"
 	NOSQL DatabaseType = "nosql"
@@ -54,72 +153,288 @@ const (
 	MARIADB    DatabaseEngine = "mariadb"
 	POSTGRESQL DatabaseEngine = "psql"
 	MONGODB    DatabaseEngine = "mongodb"
+	SQLITE     DatabaseEngine = "sqlite"
+	CLICKHOUSE DatabaseEngine = "clickhouse"
+	MEMORY     DatabaseEngine = "memory"
 )
 
 func (d DatabaseEngine) String() string {
 	return string(d)
 }
 
+// FieldError is a validation error of a config field, Path is yaml path of the field, e.g. dbs[1].engine.
+type FieldError struct {
+	Path string
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+func fieldErr(path, format string, args ...any) error {
+	return &FieldError{Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Validate checks every field and returns all errors joined, each one is a *FieldError.
 func (c *Config) Validate() error {
+	errs := make([]error, 0)
+
 	if c.LastBlockHeight == 0 {
-		return errors.New("you cannot set 0 for last_block_height, first block is 1")
+		errs = append(errs, fieldErr("last_block_height", "you cannot set 0 for last_block_height, first block is 1"))
 	}
 
 	if c.SyncIntervalPerBlock < 3 || c.SyncIntervalPerBlock > 86400 {
-		return errors.New("minimum sync_interval_per_block is 3 second and max is 86400 or 24 hours")
+		errs = append(errs, fieldErr("sync_interval_per_block",
+			"minimum sync_interval_per_block is 3 second and max is 86400 or 24 hours"))
 	}
 
-	_, err := uuid.Parse(c.IndexerUuid)
-	if err != nil {
-		return fmt.Errorf("indexer_uuid is invalid, please set this uuid %s", uuid.New().String())
+	if c.ShutdownTimeout < 0 {
+		errs = append(errs, fieldErr("shutdown_timeout", "shutdown_timeout can't be negative"))
 	}
 
-	if len(c.DBS) == 0 {
-		return errors.New("dbs is null, need 1 database engine for sync")
+	if _, err := uuid.Parse(c.IndexerUuid); err != nil {
+		errs = append(errs, fieldErr("indexer_uuid", "indexer_uuid is invalid, please set this uuid %s",
+			uuid.New().String()))
 	}
 
-	if c.Pactus == nil {
-		return errors.New("pactus config is null")
+	if len(c.DBS) == 0 && len(c.Sinks) == 0 {
+		errs = append(errs, fieldErr("dbs", "dbs and sinks are null, need 1 database engine or sink for sync"))
 	}
 
-	if len(c.Pactus.RPC) == 0 {
-		return errors.New("pactus rpc address is empty")
+	if c.Pactus == nil {
+		errs = append(errs, fieldErr("pactus", "pactus config is null"))
+	} else if len(c.Pactus.RPC) == 0 {
+		errs = append(errs, fieldErr("pactus.rpc", "pactus rpc address is empty"))
 	}
 
-	for _, db := range c.DBS {
+	dbNames := make(map[string]int, len(c.DBS))
+	for i, db := range c.DBS {
+		path := fmt.Sprintf("dbs[%d]", i)
+
 		if len(db.Name) == 0 {
-			return errors.New("db name is null, please set a name for database engine")
+			errs = append(errs, fieldErr(path+".name", "db name is null, please set a name for database engine"))
+		} else if first, ok := dbNames[db.Name]; ok {
+			errs = append(errs, fieldErr(path+".name", "db name %s is duplicated, first used by dbs[%d]",
+				db.Name, first))
+		} else {
+			dbNames[db.Name] = i
 		}
 
-		isValidDBType := false
-
 		switch db.Type {
 		case SQL, NOSQL:
-			isValidDBType = true
+		default:
+			errs = append(errs, fieldErr(path+".type",
+				"db type is invalid, please set a type for database engine (sql, nosql)"))
 		}
 
-		if !isValidDBType {
-			return errors.New("db type is invalid, please set a type for database engine (sql, nosql)")
-		}
-
-		isValidDBEngine := false
-
 		switch db.Engine {
-		case MYSQL, POSTGRESQL, MARIADB:
-			isValidDBEngine = true
+		case MYSQL, POSTGRESQL, MARIADB, SQLITE, CLICKHOUSE, MEMORY:
 		case MONGODB:
-			isValidDBEngine = true
 			if len(db.Database) == 0 {
-				return errors.New("database name is required for mongodb")
+				errs = append(errs, fieldErr(path+".database", "database name is required for mongodb"))
+			}
+		default:
+			errs = append(errs, fieldErr(path+".engine", "db engine is invalid, please set a engine "+
+				"for database engine (mysql, psql, mariadb, mongodb, sqlite, clickhouse, memory)"))
+		}
+
+		if len(db.URI) == 0 && db.Engine != MEMORY {
+			errs = append(errs, fieldErr(path+".uri", "db uri is empty"))
+		}
+
+		errs = append(errs, db.validateConnection(path)...)
+	}
+
+	sinkNames := make(map[string]struct{}, len(c.Sinks))
+	for i, sink := range c.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+
+		if len(sink.Name) == 0 {
+			errs = append(errs, fieldErr(path+".name", "sink name is null, please set a name for sink"))
+		} else if _, ok := sinkNames[sink.Name]; ok {
+			errs = append(errs, fieldErr(path+".name", "sink name %s is duplicated", sink.Name))
+		}
+		sinkNames[sink.Name] = struct{}{}
+
+		switch sink.Type {
+		case FILE, NDJSON, CSV, PARQUET:
+			if len(sink.Path) == 0 {
+				errs = append(errs, fieldErr(path+".path", "sink %s path is empty", sink.Name))
+			}
+		default:
+			errs = append(errs, fieldErr(path+".type",
+				"sink %s type is invalid, please set a type for sink (file, ndjson, csv, parquet)", sink.Name))
+		}
+	}
+
+	if c.Webhooks != nil {
+		errs = append(errs, c.Webhooks.validate(c.DBS)...)
+	}
+
+	if c.HTTP != nil && len(c.HTTP.Listen) != 0 {
+		if _, _, err := net.SplitHostPort(c.HTTP.Listen); err != nil {
+			errs = append(errs, fieldErr("http.listen", "listen address is invalid: %s", err))
+		}
+	}
+
+	if c.HTTP != nil && c.HTTP.ReadyMaxLag < 0 {
+		errs = append(errs, fieldErr("http.ready_max_lag", "ready_max_lag can't be negative"))
+	}
+
+	if c.Tracing != nil {
+		errs = append(errs, c.Tracing.validate()...)
+	}
+
+	if c.GapRepair != nil && c.GapRepair.Interval < 0 {
+		errs = append(errs, fieldErr("gap_repair.interval", "interval can't be negative"))
+	}
+
+	if c.GapRepair != nil && c.GapRepair.FullScanInterval < 0 {
+		errs = append(errs, fieldErr("gap_repair.full_scan_interval", "full_scan_interval can't be negative"))
+	}
+
+	if c.GapRepair != nil && c.GapRepair.BatchSize < 0 {
+		errs = append(errs, fieldErr("gap_repair.batch_size", "batch_size can't be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Tracing) validate() []error {
+	errs := make([]error, 0)
+
+	switch t.Exporter {
+	case OTLPExporter:
+	case FileExporter:
+		if len(t.Path) == 0 {
+			errs = append(errs, fieldErr("tracing.path", "path is required for file exporter"))
+		}
+	default:
+		errs = append(errs, fieldErr("tracing.exporter", "tracing exporter is invalid (otlp, file)"))
+	}
+
+	if t.SampleRate < 0 || t.SampleRate > 1 {
+		errs = append(errs, fieldErr("tracing.sample_rate", "sample_rate must be between 0 and 1"))
+	}
+
+	return errs
+}
+
+func (w *Webhooks) validate(dbs []*DB) []error {
+	errs := make([]error, 0)
+
+	if len(w.Targets) != 0 && len(dbs) == 0 {
+		errs = append(errs, fieldErr("webhooks", "webhooks need a database for outbox"))
+	}
+
+	if len(w.OutboxDB) != 0 {
+		found := false
+		for _, db := range dbs {
+			if db.Name == w.OutboxDB {
+				found = true
+				break
 			}
 		}
 
-		if !isValidDBEngine {
-			return errors.New("db engine is invalid, please set a engine " +
-				"for database engine (mysql, psql, mariadb, mongodb)")
+		if !found {
+			errs = append(errs, fieldErr("webhooks.outbox_db", "webhook outbox_db %s is not in dbs", w.OutboxDB))
+		}
+	}
+
+	names := make(map[string]struct{}, len(w.Targets))
+	for i, t := range w.Targets {
+		path := fmt.Sprintf("webhooks.targets[%d]", i)
+
+		if len(t.Name) == 0 {
+			errs = append(errs, fieldErr(path+".name", "webhook name is null, please set a name for webhook"))
+		} else if _, ok := names[t.Name]; ok {
+			errs = append(errs, fieldErr(path+".name", "webhook name %s is duplicated", t.Name))
+		}
+		names[t.Name] = struct{}{}
+
+		u, err := url.Parse(t.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs = append(errs, fieldErr(path+".url", "webhook %s url is invalid", t.Name))
+		}
+
+		switch t.Event {
+		case BlockEvent, TransactionEvent:
+		default:
+			errs = append(errs, fieldErr(path+".event",
+				"webhook %s event is invalid, please set an event (block, transaction)", t.Name))
+		}
+
+		if t.MaxRetries < -1 {
+			errs = append(errs, fieldErr(path+".max_retries",
+				"webhook %s max_retries is invalid, please set -1 to disable retries", t.Name))
+		}
+
+		if t.Timeout < 0 {
+			errs = append(errs, fieldErr(path+".timeout", "webhook %s timeout can't be negative", t.Name))
+		}
+	}
+
+	return errs
+}
+
+func (d *DB) validateConnection(path string) []error {
+	errs := make([]error, 0)
+
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"max_open_conns", d.MaxOpenConns},
+		{"max_idle_conns", d.MaxIdleConns},
+		{"conn_max_lifetime", d.ConnMaxLifetime},
+		{"connect_timeout", d.ConnectTimeout},
+		{"query_timeout", d.QueryTimeout},
+		{"write_timeout", d.WriteTimeout},
+	} {
+		if f.value < 0 {
+			errs = append(errs, fieldErr(path+"."+f.name, "%s can't be negative", f.name))
+		}
+	}
+
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, fieldErr(path+".max_idle_conns", "max_idle_conns can't be more than max_open_conns"))
+	}
+
+	if d.Engine == SQLITE && d.MaxOpenConns > 1 {
+		errs = append(errs, fieldErr(path+".max_open_conns",
+			"sqlite allows a single writer, max_open_conns can't be more than 1"))
+	}
+
+	if d.Engine != MONGODB {
+		if len(d.WriteConcern) != 0 {
+			errs = append(errs, fieldErr(path+".write_concern", "write_concern is only for mongodb"))
+		}
+
+		if len(d.ReadPreference) != 0 {
+			errs = append(errs, fieldErr(path+".read_preference", "read_preference is only for mongodb"))
 		}
 
+		return errs
+	}
+
+	// mongo driver has no max lifetime of a connection, only a max idle time
+	if d.ConnMaxLifetime != 0 {
+		errs = append(errs, fieldErr(path+".conn_max_lifetime", "conn_max_lifetime isn't supported by mongodb"))
+	}
+
+	if len(d.WriteConcern) != 0 && d.WriteConcern != "majority" {
+		if w, err := strconv.Atoi(d.WriteConcern); err != nil || w < 0 {
+			errs = append(errs, fieldErr(path+".write_concern", "write_concern must be majority or number of nodes"))
+		}
+	}
+
+	switch strings.ToLower(d.ReadPreference) {
+	case "", "primary", "primarypreferred", "secondary", "secondarypreferred", "nearest":
+	default:
+		errs = append(errs, fieldErr(path+".read_preference", "read_preference is invalid, please set one of "+
+			"(primary, primaryPreferred, secondary, secondaryPreferred, nearest)"))
 	}
 
-	return nil
+	return errs
 }
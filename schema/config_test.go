@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDB_ValidateConnection(t *testing.T) {
+	tests := []struct {
+		db  DB
+		err string
+	}{
+		{DB{Engine: MYSQL, MaxOpenConns: 20, MaxIdleConns: 5, QueryTimeout: 10}, ""},
+		{DB{Engine: MONGODB, WriteConcern: "majority", ReadPreference: "secondaryPreferred"}, ""},
+		{DB{Engine: MONGODB, WriteConcern: "2"}, ""},
+		{DB{Engine: MYSQL, WriteTimeout: -1}, "dbs[0].write_timeout"},
+		{DB{Engine: MYSQL, MaxOpenConns: 2, MaxIdleConns: 5}, "dbs[0].max_idle_conns"},
+		{DB{Engine: SQLITE, MaxOpenConns: 4}, "single writer"},
+		{DB{Engine: POSTGRESQL, ReadPreference: "primary"}, "only for mongodb"},
+		{DB{Engine: MONGODB, WriteConcern: "all"}, "dbs[0].write_concern"},
+		{DB{Engine: MONGODB, ReadPreference: "closest"}, "dbs[0].read_preference"},
+		{DB{Engine: MONGODB, ConnMaxLifetime: 3600}, "dbs[0].conn_max_lifetime"},
+		{DB{Engine: POSTGRESQL, ConnMaxLifetime: 3600}, ""},
+	}
+
+	for _, tt := range tests {
+		err := errors.Join(tt.db.validateConnection("dbs[0]")...)
+		if len(tt.err) == 0 && err != nil {
+			t.Errorf("%+v: unexpected error %v", tt.db, err)
+		}
+		if len(tt.err) != 0 && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%+v: expected error with %q, got %v", tt.db, tt.err, err)
+		}
+	}
+}
+
+func TestConfig_ValidateReportsAll(t *testing.T) {
+	cfg := &Config{
+		LastBlockHeight:      1,
+		SyncIntervalPerBlock: 5,
+		IndexerUuid:          "8bc5d30c-1ccc-460c-adcc-508608b0c188",
+		DBS: []*DB{
+			{Name: "main", Type: SQL, Engine: SQLITE, URI: "file:a.db"},
+			{Name: "main", Type: SQL, Engine: "oracle", URI: "oracle://"},
+		},
+	}
+
+	err := cfg.Validate()
+
+	var paths []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fe *FieldError
+		if !errors.As(e, &fe) {
+			t.Fatalf("expected field error, got %v", e)
+		}
+		paths = append(paths, fe.Path)
+	}
+
+	expected := "pactus,dbs[1].name,dbs[1].engine"
+	if strings.Join(paths, ",") != expected {
+		t.Fatalf("expected errors of %s, got %v", expected, paths)
+	}
+}
@@ -5,47 +5,83 @@ import (
 )
 
 const (
-	BlockTableName        = "blocks"
-	TransactionsTableName = "transactions"
-	IndexerTableName      = "indexers"
+	BlockTableName         = "blocks"
+	TransactionsTableName  = "transactions"
+	IndexerTableName       = "indexers"
+	WebhookOutboxTableName = "webhook_outbox"
+	MigrationsTableName    = "schema_migrations"
 )
 
 type Block struct {
-	ID                uint    `bson:"-" gorm:"primarykey"`
-	Height            uint32  `bson:"height" gorm:"column:height;uniqueIndex"`
-	Hash              string  `bson:"hash" gorm:"column:hash;uniqueIndex;size:100"`
-	TotalTransactions uint    `bson:"total_transactions" gorm:"column:total_transactions"`
-	BlockTime         uint32  `bson:"block_time" gorm:"column:block_time;index"`
-	BlockReward       int64   `bson:"block_reward" gorm:"column:block_reward"`
-	Version           int32   `bson:"version" gorm:"column:version"`
-	PrevBlockHash     string  `bson:"prev_block_hash,omitempty" gorm:"column:prev_block_hash;index"`
-	StateRoot         string  `bson:"state_root,omitempty" gorm:"column:state_root"`
-	SortitionSeed     string  `bson:"sortition_seed,omitempty" gorm:"column:sortition_seed"`
-	ProposerAddress   string  `bson:"proposer_address" gorm:"column:proposer_address;index"`
-	CertificateHash   string  `bson:"certificate_hash,omitempty" gorm:"column:certificate_hash;index"`
-	Round             int32   `bson:"round,omitempty" gorm:"column:round"`
-	Committers        []int32 `bson:"committers,omitempty" gorm:"serializer:json"`
-	Absentees         []int32 `bson:"absentees,omitempty" gorm:"serializer:json"`
-	Signature         string  `bson:"signature,omitempty" gorm:"column:signature"`
+	ID                uint    `bson:"-" ch:"-" gorm:"primarykey" json:"-"`
+	Height            uint32  `bson:"height" ch:"height" gorm:"column:height;uniqueIndex" json:"height"`
+	Hash              string  `bson:"hash" ch:"hash" gorm:"column:hash;uniqueIndex;size:100" json:"hash"`
+	TotalTransactions uint    `bson:"total_transactions" ch:"total_transactions" gorm:"column:total_transactions" json:"total_transactions"`
+	BlockTime         uint32  `bson:"block_time" ch:"block_time" gorm:"column:block_time;index" json:"block_time"`
+	BlockReward       int64   `bson:"block_reward" ch:"block_reward" gorm:"column:block_reward" json:"block_reward"`
+	Version           int32   `bson:"version" ch:"version" gorm:"column:version" json:"version"`
+	PrevBlockHash     string  `bson:"prev_block_hash,omitempty" ch:"prev_block_hash" gorm:"column:prev_block_hash;index" json:"prev_block_hash,omitempty"`
+	StateRoot         string  `bson:"state_root,omitempty" ch:"state_root" gorm:"column:state_root" json:"state_root,omitempty"`
+	SortitionSeed     string  `bson:"sortition_seed,omitempty" ch:"sortition_seed" gorm:"column:sortition_seed" json:"sortition_seed,omitempty"`
+	ProposerAddress   string  `bson:"proposer_address" ch:"proposer_address" gorm:"column:proposer_address;index" json:"proposer_address"`
+	CertificateHash   string  `bson:"certificate_hash,omitempty" ch:"certificate_hash" gorm:"column:certificate_hash;index" json:"certificate_hash,omitempty"`
+	Round             int32   `bson:"round,omitempty" ch:"round" gorm:"column:round" json:"round,omitempty"`
+	Committers        []int32 `bson:"committers,omitempty" ch:"committers" gorm:"serializer:json" json:"committers,omitempty"`
+	Absentees         []int32 `bson:"absentees,omitempty" ch:"absentees" gorm:"serializer:json" json:"absentees,omitempty"`
+	Signature         string  `bson:"signature,omitempty" ch:"signature" gorm:"column:signature" json:"signature,omitempty"`
 }
 
 type Transaction struct {
-	ID          uint      `bson:"-" gorm:"primarykey"`
-	Hash        string    `bson:"hash" gorm:"column:hash;uniqueIndex;size:100"`
-	BlockHeight uint32    `bson:"block_height" gorm:"column:block_height;index"`
-	Version     int32     `bson:"version,omitempty" gorm:"column:block_height"`
-	Type        string    `bson:"type" gorm:"column:type;index"`
-	From        string    `bson:"from,omitempty" gorm:"column:from;index"`
-	To          string    `bson:"to,omitempty" gorm:"column:to;index"`
-	Value       int64     `bson:"value,omitempty" gorm:"column:value;index"`
-	Fee         int64     `bson:"fee,omitempty" gorm:"column:fee;index"`
-	Memo        string    `bson:"memo,omitempty" gorm:"column:memo"`
-	CreatedAt   time.Time `bson:"created_at" gorm:"column:created_at;index"`
+	ID          uint      `bson:"-" ch:"-" gorm:"primarykey" json:"-"`
+	Hash        string    `bson:"hash" ch:"hash" gorm:"column:hash;uniqueIndex;size:100" json:"hash"`
+	BlockHeight uint32    `bson:"block_height" ch:"block_height" gorm:"column:block_height;index" json:"block_height"`
+	Version     int32     `bson:"version,omitempty" ch:"version" gorm:"column:version" json:"version,omitempty"`
+	Type        string    `bson:"type" ch:"type" gorm:"column:type;index" json:"type"`
+	From        string    `bson:"from,omitempty" ch:"from" gorm:"column:from;index" json:"from,omitempty"`
+	To          string    `bson:"to,omitempty" ch:"to" gorm:"column:to;index" json:"to,omitempty"`
+	Value       int64     `bson:"value,omitempty" ch:"value" gorm:"column:value;index" json:"value,omitempty"`
+	Fee         int64     `bson:"fee,omitempty" ch:"fee" gorm:"column:fee;index" json:"fee,omitempty"`
+	Memo        string    `bson:"memo,omitempty" ch:"memo" gorm:"column:memo" json:"memo,omitempty"`
+	CreatedAt   time.Time `bson:"created_at" ch:"created_at" gorm:"column:created_at;index" json:"created_at"`
 }
 
+// Indexer keeps the sync cursor of an indexer, LastBlockHeight is the next block height to fetch.
 type Indexer struct {
-	ID              uint      `bson:"-" gorm:"primarykey"`
-	IndexId         string    `bson:"index_id" gorm:"column:index_id;uniqueIndex;size:36"`
-	LastBlockHeight int       `bson:"last_block_height" gorm:"column:last_block_height"`
-	IndexedAt       time.Time `bson:"indexed_at" gorm:"column:indexed_at"`
+	ID              uint      `bson:"-" ch:"-" gorm:"primarykey"`
+	IndexId         string    `bson:"index_id" ch:"index_id" gorm:"column:index_id;uniqueIndex;size:36"`
+	LastBlockHeight int       `bson:"last_block_height" ch:"last_block_height" gorm:"column:last_block_height"`
+	IndexedAt       time.Time `bson:"indexed_at" ch:"indexed_at" gorm:"column:indexed_at"`
+}
+
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
+
+// WebhookOutbox is a durable webhook delivery, rows stay pending until the target accepts them or retries run out.
+type WebhookOutbox struct {
+	ID            uint      `bson:"-" ch:"-" gorm:"primarykey"`
+	EventId       string    `bson:"event_id" ch:"event_id" gorm:"column:event_id;uniqueIndex;size:36"`
+	Webhook       string    `bson:"webhook" ch:"webhook" gorm:"column:webhook;index;size:100"`
+	Event         string    `bson:"event" ch:"event" gorm:"column:event;size:20"`
+	BlockHeight   uint32    `bson:"block_height" ch:"block_height" gorm:"column:block_height;index"`
+	Payload       string    `bson:"payload" ch:"payload" gorm:"column:payload;type:text"`
+	Status        string    `bson:"status" ch:"status" gorm:"column:status;index;size:20"`
+	Attempts      int       `bson:"attempts" ch:"attempts" gorm:"column:attempts"`
+	NextAttemptAt time.Time `bson:"next_attempt_at" ch:"next_attempt_at" gorm:"column:next_attempt_at;index"`
+	LastError     string    `bson:"last_error,omitempty" ch:"last_error" gorm:"column:last_error;type:text"`
+	CreatedAt     time.Time `bson:"created_at" ch:"created_at" gorm:"column:created_at"`
+}
+
+// TableName keeps gorm from pluralizing the outbox table, queries address it by WebhookOutboxTableName.
+func (WebhookOutbox) TableName() string {
+	return WebhookOutboxTableName
+}
+
+// SchemaMigration is an applied migration version of sql engines, mongodb keeps versions with mongo-migrate.
+type SchemaMigration struct {
+	Version     uint      `gorm:"column:version;primarykey;autoIncrement:false"`
+	Description string    `gorm:"column:description;size:255"`
+	AppliedAt   time.Time `gorm:"column:applied_at"`
 }
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/client"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/metrics"
+	pactus "github.com/pactus-project/pactus/www/grpc/gen/go"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusDown = "down"
+
+	_defaultCheckTimeout = 5 * time.Second
+)
+
+// Component is status of a dependency in readiness report.
+type Component struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report is json body of health endpoints.
+type Report struct {
+	Status     string      `json:"status"`
+	Components []Component `json:"components,omitempty"`
+}
+
+// Checker reports status of one or more components.
+type Checker interface {
+	Check(ctx context.Context) []Component
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) []Component
+
+func (f CheckerFunc) Check(ctx context.Context) []Component {
+	return f(ctx)
+}
+
+// Healthz reports process is alive, it doesn't check dependencies so a slow database doesn't restart pod.
+func Healthz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, Report{Status: StatusOK})
+	})
+}
+
+// Readyz runs every checker and responds 503 when any component is down.
+func Readyz(checkers ...Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), _defaultCheckTimeout)
+		defer cancel()
+
+		report := Report{Status: StatusOK, Components: make([]Component, 0)}
+		for _, c := range checkers {
+			for _, comp := range c.Check(ctx) {
+				if comp.Status != StatusOK {
+					report.Status = StatusDown
+				}
+				report.Components = append(report.Components, comp)
+			}
+		}
+
+		writeReport(w, report)
+	})
+}
+
+// DatabaseCheck pings every database of pool.
+func DatabaseCheck(pool *db.Pool) Checker {
+	return CheckerFunc(func(ctx context.Context) []Component {
+		health := pool.Ping(ctx)
+		components := make([]Component, 0, len(health))
+
+		for _, h := range health {
+			comp := Component{Name: "db/" + h.Name, Status: StatusOK, Latency: h.Latency.String()}
+			if h.Err != nil {
+				comp.Status = StatusDown
+				comp.Error = h.Err.Error()
+			}
+			components = append(components, comp)
+		}
+
+		return components
+	})
+}
+
+// PactusCheck calls pactus node, it's down when blockchain info can't be read.
+func PactusCheck(node *client.Pactus) Checker {
+	return CheckerFunc(func(ctx context.Context) []Component {
+		start := time.Now()
+		_, err := node.Blockchain.GetBlockchainInfo(ctx, &pactus.GetBlockchainInfoRequest{})
+
+		comp := Component{Name: "pactus", Status: StatusOK, Latency: time.Since(start).String()}
+		if err != nil {
+			comp.Status = StatusDown
+			comp.Error = err.Error()
+		}
+
+		return []Component{comp}
+	})
+}
+
+// LagCheck is down for every database or sink more than maxLag blocks behind chain tip.
+func LagCheck(maxLag uint32) Checker {
+	return CheckerFunc(func(context.Context) []Component {
+		lag := metrics.Lag()
+		names := make([]string, 0, len(lag))
+		for name := range lag {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		components := make([]Component, 0, len(names))
+		for _, name := range names {
+			comp := Component{Name: "lag/" + name, Status: StatusOK}
+			if b := lag[name].Blocks; b > maxLag {
+				comp.Status = StatusDown
+				comp.Error = fmt.Sprintf("%d blocks behind chain tip, max is %d", b, maxLag)
+			}
+			components = append(components, comp)
+		}
+
+		return components
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	pool := dbtest.MemoryPool(t, "memory")
+
+	node := CheckerFunc(func(context.Context) []Component {
+		return []Component{{Name: "pactus", Status: StatusDown, Error: "connection refused"}}
+	})
+
+	rec := httptest.NewRecorder()
+	Readyz(DatabaseCheck(pool), node).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != StatusDown || len(report.Components) != 2 ||
+		report.Components[0].Name != "db/memory" || report.Components[0].Status != StatusOK {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	Readyz(DatabaseCheck(pool)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	_defaultShutdownTimeout  = 5 * time.Second
+	_defaultReadHeaderTimout = 10 * time.Second
+)
+
+// Server serves operational endpoints of a running indexer, e.g. metrics.
+type Server struct {
+	mux    *http.ServeMux
+	listen string
+	logger logging.Logger
+}
+
+func New(listen string, logger logging.Logger) *Server {
+	return &Server{
+		mux:    http.NewServeMux(),
+		listen: listen,
+		logger: logger,
+	}
+}
+
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Run serves until context is canceled, then waits for active requests to finish.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: _defaultReadHeaderTimout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	ln, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, false, "HTTP server started", "listen", ln.Addr().String())
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), _defaultShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"encoding/json"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strconv"
+	"time"
+)
+
+var (
+	blockColumns = []string{
+		"height", "hash", "total_transactions", "block_time", "block_reward", "version", "prev_block_hash",
+		"state_root", "sortition_seed", "proposer_address", "certificate_hash", "round", "committers",
+		"absentees", "signature",
+	}
+
+	transactionColumns = []string{
+		"hash", "block_height", "version", "type", "from", "to", "value", "fee", "memo", "created_at",
+	}
+)
+
+func blockRecord(b *schema.Block) []string {
+	return []string{
+		strconv.FormatUint(uint64(b.Height), 10),
+		b.Hash,
+		strconv.FormatUint(uint64(b.TotalTransactions), 10),
+		strconv.FormatUint(uint64(b.BlockTime), 10),
+		strconv.FormatInt(b.BlockReward, 10),
+		strconv.FormatInt(int64(b.Version), 10),
+		b.PrevBlockHash,
+		b.StateRoot,
+		b.SortitionSeed,
+		b.ProposerAddress,
+		b.CertificateHash,
+		strconv.FormatInt(int64(b.Round), 10),
+		intsJSON(b.Committers),
+		intsJSON(b.Absentees),
+		b.Signature,
+	}
+}
+
+func transactionRecord(t *schema.Transaction) []string {
+	return []string{
+		t.Hash,
+		strconv.FormatUint(uint64(t.BlockHeight), 10),
+		strconv.FormatInt(int64(t.Version), 10),
+		t.Type,
+		t.From,
+		t.To,
+		strconv.FormatInt(t.Value, 10),
+		strconv.FormatInt(t.Fee, 10),
+		t.Memo,
+		t.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// intsJSON encodes committers and absentees as json array, so warehouses can parse them as array column.
+func intsJSON(v []int32) string {
+	if v == nil {
+		v = []int32{}
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
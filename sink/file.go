@@ -0,0 +1,155 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	RecordBlock       = "block"
+	RecordTransaction = "transaction"
+
+	checkpointExt = ".checkpoint"
+)
+
+// Record is a single line of file sink.
+type Record struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type checkpoint struct {
+	Height uint32 `json:"height"`
+	Offset int64  `json:"offset"`
+}
+
+// File appends every block and transaction as a json line to a single file. Offset of the last checkpoint
+// is kept next to the file, anything written after it is truncated on open or rollback.
+type File struct {
+	name string
+	path string
+	file *os.File
+	w    *bufio.Writer
+	cp   checkpoint
+	m    sync.Mutex
+}
+
+func NewFile(cfg *schema.Sink) (db.Sink, error) {
+	f := &File{
+		name: cfg.Name,
+		path: cfg.Path,
+	}
+
+	var cp checkpoint
+	if err := readState(f.path+checkpointExt, &cp); err != nil {
+		return nil, err
+	}
+	f.cp = cp
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop rows of a block that was never checkpointed, e.g. process crashed mid write
+	if err := file.Truncate(cp.Offset); err != nil {
+		return nil, errors.Join(file.Close(), err)
+	}
+
+	if _, err := file.Seek(cp.Offset, io.SeekStart); err != nil {
+		return nil, errors.Join(file.Close(), err)
+	}
+
+	f.file = file
+	f.w = bufio.NewWriter(file)
+
+	return f, nil
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+func (f *File) WriteBlock(_ context.Context, block *schema.Block, txs []*schema.Transaction) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	enc := json.NewEncoder(f.w)
+	if err := enc.Encode(Record{Type: RecordBlock, Data: block}); err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		if err := enc.Encode(Record{Type: RecordTransaction, Data: tx}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *File) Rollback(_ context.Context, height uint32) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if height < f.cp.Height {
+		return fmt.Errorf("can't rollback to %d, last checkpoint is %d", height, f.cp.Height)
+	}
+
+	f.w.Reset(f.file)
+	if err := f.file.Truncate(f.cp.Offset); err != nil {
+		return err
+	}
+
+	_, err := f.file.Seek(f.cp.Offset, io.SeekStart)
+	return err
+}
+
+func (f *File) Checkpoint(_ context.Context, height uint32) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+
+	offset, err := f.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	cp := checkpoint{Height: height, Offset: offset}
+	if err := writeState(f.path+checkpointExt, cp); err != nil {
+		return err
+	}
+	f.cp = cp
+
+	return nil
+}
+
+func (f *File) Cursor(_ context.Context) (uint32, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.cp.Height, nil
+}
+
+// Close closes file without checkpoint, unflushed rows are dropped on next open.
+func (f *File) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return errors.Join(f.w.Flush(), f.file.Close())
+}
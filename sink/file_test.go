@@ -0,0 +1,113 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testBlock(height uint32) (*schema.Block, []*schema.Transaction) {
+	return &schema.Block{Height: height, Hash: "h"}, []*schema.Transaction{
+		{Hash: "t1", BlockHeight: height, Type: "transfer"},
+		{Hash: "t2", BlockHeight: height, Type: "bond"},
+	}
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records := make([]Record, 0)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+
+	return records
+}
+
+func TestFile_CheckpointAndRollback(t *testing.T) {
+	ctx := context.Background()
+	cfg := &schema.Sink{Name: "file", Type: schema.FILE, Path: filepath.Join(t.TempDir(), "chain.ndjson")}
+
+	s, err := NewFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, txs := testBlock(1)
+	if err := s.WriteBlock(ctx, block, txs); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Checkpoint(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	block, txs = testBlock(2)
+	if err := s.WriteBlock(ctx, block, txs); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Rollback(ctx, 0); err == nil {
+		t.Fatal("expected error on rollback behind checkpoint")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := readRecords(t, cfg.Path); len(records) != 3 || records[0].Type != RecordBlock {
+		t.Fatalf("expected block 1 with 2 transactions, got %d records", len(records))
+	}
+}
+
+func TestFile_TruncateUncheckpointed(t *testing.T) {
+	ctx := context.Background()
+	cfg := &schema.Sink{Name: "file", Type: schema.FILE, Path: filepath.Join(t.TempDir(), "chain.ndjson")}
+
+	s, err := NewFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, txs := testBlock(1)
+	_ = s.WriteBlock(ctx, block, txs)
+	_ = s.Checkpoint(ctx, 1)
+	block, txs = testBlock(2)
+	_ = s.WriteBlock(ctx, block, txs)
+	_ = s.Close()
+
+	s, err = NewFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	cursor, err := s.Cursor(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursor != 1 {
+		t.Fatalf("expected cursor 1, got %d", cursor)
+	}
+
+	if records := readRecords(t, cfg.Path); len(records) != 3 {
+		t.Fatalf("expected 3 records after reopen, got %d", len(records))
+	}
+}
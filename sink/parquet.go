@@ -0,0 +1,446 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/parquet-go/parquet-go"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	_defaultParquetPartSize = 10000
+
+	parquetStateFile = "checkpoint.json"
+	dayLayout        = "2006-01-02"
+)
+
+type parquetBlock struct {
+	Height            uint32    `parquet:"height"`
+	Hash              string    `parquet:"hash"`
+	TotalTransactions uint32    `parquet:"total_transactions"`
+	BlockTime         time.Time `parquet:"block_time,timestamp(millisecond)"`
+	BlockReward       int64     `parquet:"block_reward"`
+	Version           int32     `parquet:"version"`
+	PrevBlockHash     string    `parquet:"prev_block_hash"`
+	StateRoot         string    `parquet:"state_root"`
+	SortitionSeed     string    `parquet:"sortition_seed"`
+	ProposerAddress   string    `parquet:"proposer_address,dict"`
+	CertificateHash   string    `parquet:"certificate_hash"`
+	Round             int32     `parquet:"round"`
+	Committers        []int32   `parquet:"committers,list"`
+	Absentees         []int32   `parquet:"absentees,list"`
+	Signature         string    `parquet:"signature"`
+}
+
+type parquetTransaction struct {
+	Hash        string    `parquet:"hash"`
+	BlockHeight uint32    `parquet:"block_height"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	Version     int32     `parquet:"version"`
+	Type        string    `parquet:"type,dict"`
+	From        string    `parquet:"from,dict"`
+	To          string    `parquet:"to,dict"`
+	Value       int64     `parquet:"value"`
+	Fee         int64     `parquet:"fee"`
+	Memo        string    `parquet:"memo"`
+}
+
+type parquetTransfer struct {
+	TxHash      string    `parquet:"tx_hash"`
+	BlockHeight uint32    `parquet:"block_height"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	Sender      string    `parquet:"sender,dict"`
+	Receiver    string    `parquet:"receiver,dict"`
+	Amount      int64     `parquet:"amount"`
+}
+
+type parquetBond struct {
+	TxHash      string    `parquet:"tx_hash"`
+	BlockHeight uint32    `parquet:"block_height"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	Sender      string    `parquet:"sender,dict"`
+	Validator   string    `parquet:"validator,dict"`
+	Stake       int64     `parquet:"stake"`
+}
+
+type parquetSortition struct {
+	TxHash      string    `parquet:"tx_hash"`
+	BlockHeight uint32    `parquet:"block_height"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	Address     string    `parquet:"address,dict"`
+}
+
+type parquetUnbond struct {
+	TxHash      string    `parquet:"tx_hash"`
+	BlockHeight uint32    `parquet:"block_height"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	Validator   string    `parquet:"validator,dict"`
+}
+
+type parquetWithdraw struct {
+	TxHash      string    `parquet:"tx_hash"`
+	BlockHeight uint32    `parquet:"block_height"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	From        string    `parquet:"from,dict"`
+	To          string    `parquet:"to,dict"`
+	Amount      int64     `parquet:"amount"`
+}
+
+// parquetBuffer keeps rows of a table in memory until they're written as a part file.
+type parquetBuffer interface {
+	len() int
+	truncate(height uint32)
+	// write stores rows in a temporary file next to path and returns its name, empty when buffer has no rows.
+	write(path string) (string, error)
+	reset()
+}
+
+type rowBuffer[T any] struct {
+	rows    []T
+	heights []uint32
+}
+
+func (b *rowBuffer[T]) add(height uint32, row T) {
+	b.rows = append(b.rows, row)
+	b.heights = append(b.heights, height)
+}
+
+func (b *rowBuffer[T]) len() int {
+	return len(b.rows)
+}
+
+// truncate drops rows written after height.
+func (b *rowBuffer[T]) truncate(height uint32) {
+	i := len(b.heights)
+	for i > 0 && b.heights[i-1] > height {
+		i--
+	}
+
+	b.rows = b.rows[:i]
+	b.heights = b.heights[:i]
+}
+
+func (b *rowBuffer[T]) write(path string) (string, error) {
+	if len(b.rows) == 0 {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp := path + ".tmp"
+	if err := parquet.WriteFile(tmp, b.rows, parquet.Compression(&parquet.Zstd)); err != nil {
+		return "", errors.Join(err, removeIfExists(tmp))
+	}
+
+	return tmp, nil
+}
+
+func (b *rowBuffer[T]) reset() {
+	b.rows = b.rows[:0]
+	b.heights = b.heights[:0]
+}
+
+type parquetState struct {
+	Height uint32 `json:"height"`
+}
+
+// Parquet writes blocks, transactions and payload tables as zstd compressed parquet files, partitioned by
+// day of block time in hive layout (<table>/date=YYYY-MM-DD/part-<from>-<to>.parquet).
+// Rows are buffered in memory and written when the day changes, part_size blocks are buffered or on Close,
+// so Cursor only moves forward when part files are written. Part files of all tables and the checkpoint are
+// written together or not at all, blocks after Cursor are written again after a restart. Blocks already
+// checkpointed in buffer are skipped, so fetching them again from Cursor doesn't duplicate rows.
+type Parquet struct {
+	name     string
+	dir      string
+	partSize int
+	day      string
+	from     uint32 // from first height in buffer, zero when buffer is empty
+	last     uint32 // last checkpointed height in buffer
+	blocks   int
+
+	tables map[string]parquetBuffer
+
+	blockRows     *rowBuffer[parquetBlock]
+	txRows        *rowBuffer[parquetTransaction]
+	transferRows  *rowBuffer[parquetTransfer]
+	bondRows      *rowBuffer[parquetBond]
+	sortitionRows *rowBuffer[parquetSortition]
+	unbondRows    *rowBuffer[parquetUnbond]
+	withdrawRows  *rowBuffer[parquetWithdraw]
+
+	state parquetState
+	m     sync.Mutex
+}
+
+func NewParquet(cfg *schema.Sink) (db.Sink, error) {
+	p := &Parquet{
+		name:          cfg.Name,
+		dir:           cfg.Path,
+		partSize:      int(cfg.SegmentSize),
+		blockRows:     &rowBuffer[parquetBlock]{},
+		txRows:        &rowBuffer[parquetTransaction]{},
+		transferRows:  &rowBuffer[parquetTransfer]{},
+		bondRows:      &rowBuffer[parquetBond]{},
+		sortitionRows: &rowBuffer[parquetSortition]{},
+		unbondRows:    &rowBuffer[parquetUnbond]{},
+		withdrawRows:  &rowBuffer[parquetWithdraw]{},
+	}
+
+	if p.partSize == 0 {
+		p.partSize = _defaultParquetPartSize
+	}
+
+	p.tables = map[string]parquetBuffer{
+		schema.BlockTableName:        p.blockRows,
+		schema.TransactionsTableName: p.txRows,
+		"payload_transfers":          p.transferRows,
+		"payload_bonds":              p.bondRows,
+		"payload_sortitions":         p.sortitionRows,
+		"payload_unbonds":            p.unbondRows,
+		"payload_withdraws":          p.withdrawRows,
+	}
+
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := readState(filepath.Join(p.dir, parquetStateFile), &p.state); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *Parquet) Name() string {
+	return p.name
+}
+
+func (p *Parquet) WriteBlock(_ context.Context, block *schema.Block, txs []*schema.Transaction) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if block.Height <= p.checkpoint() {
+		return nil
+	}
+
+	blockTime := time.Unix(int64(block.BlockTime), 0).UTC()
+	day := blockTime.Format(dayLayout)
+
+	if p.from != 0 && day != p.day {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+
+	if p.from == 0 {
+		p.from = block.Height
+		p.day = day
+	}
+
+	p.blocks++
+	p.blockRows.add(block.Height, parquetBlock{
+		Height:            block.Height,
+		Hash:              block.Hash,
+		TotalTransactions: uint32(block.TotalTransactions),
+		BlockTime:         blockTime,
+		BlockReward:       block.BlockReward,
+		Version:           block.Version,
+		PrevBlockHash:     block.PrevBlockHash,
+		StateRoot:         block.StateRoot,
+		SortitionSeed:     block.SortitionSeed,
+		ProposerAddress:   block.ProposerAddress,
+		CertificateHash:   block.CertificateHash,
+		Round:             block.Round,
+		Committers:        block.Committers,
+		Absentees:         block.Absentees,
+		Signature:         block.Signature,
+	})
+
+	for _, tx := range txs {
+		p.txRows.add(block.Height, parquetTransaction{
+			Hash:        tx.Hash,
+			BlockHeight: tx.BlockHeight,
+			BlockTime:   blockTime,
+			Version:     tx.Version,
+			Type:        tx.Type,
+			From:        tx.From,
+			To:          tx.To,
+			Value:       tx.Value,
+			Fee:         tx.Fee,
+			Memo:        tx.Memo,
+		})
+
+		p.addPayload(block.Height, blockTime, tx)
+	}
+
+	return nil
+}
+
+func (p *Parquet) addPayload(height uint32, blockTime time.Time, tx *schema.Transaction) {
+	switch tx.Type {
+	case "transfer":
+		p.transferRows.add(height, parquetTransfer{
+			TxHash: tx.Hash, BlockHeight: height, BlockTime: blockTime,
+			Sender: tx.From, Receiver: tx.To, Amount: tx.Value,
+		})
+	case "bond":
+		p.bondRows.add(height, parquetBond{
+			TxHash: tx.Hash, BlockHeight: height, BlockTime: blockTime,
+			Sender: tx.From, Validator: tx.To, Stake: tx.Value,
+		})
+	case "sortition":
+		p.sortitionRows.add(height, parquetSortition{
+			TxHash: tx.Hash, BlockHeight: height, BlockTime: blockTime, Address: tx.From,
+		})
+	case "unbond":
+		p.unbondRows.add(height, parquetUnbond{
+			TxHash: tx.Hash, BlockHeight: height, BlockTime: blockTime, Validator: tx.From,
+		})
+	case "withdraw":
+		p.withdrawRows.add(height, parquetWithdraw{
+			TxHash: tx.Hash, BlockHeight: height, BlockTime: blockTime,
+			From: tx.From, To: tx.To, Amount: tx.Value,
+		})
+	}
+}
+
+func (p *Parquet) Rollback(_ context.Context, height uint32) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if height < p.state.Height {
+		return fmt.Errorf("can't rollback to %d, last written part ends at %d", height, p.state.Height)
+	}
+
+	p.truncate(height)
+
+	return nil
+}
+
+func (p *Parquet) Checkpoint(_ context.Context, height uint32) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// a block written again is checkpointed again, last never moves back
+	p.last = max(p.last, height)
+	if p.blocks >= p.partSize {
+		return p.flush()
+	}
+
+	return nil
+}
+
+func (p *Parquet) Cursor(_ context.Context) (uint32, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.state.Height, nil
+}
+
+// Close writes checkpointed rows as part files, rows after last checkpoint are dropped.
+func (p *Parquet) Close() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.flush()
+}
+
+// flush writes checkpointed rows of every table as a part file of current day.
+func (p *Parquet) flush() error {
+	p.truncate(p.last)
+	if p.from == 0 || p.last < p.from {
+		p.reset()
+		return nil
+	}
+
+	// every table is written to a temporary file first, so a failure leaves no part of the range visible
+	part := fmt.Sprintf("part-%010d-%010d.parquet", p.from, p.last)
+	files := make([]partFile, 0, len(p.tables))
+	for name, t := range p.tables {
+		path := filepath.Join(p.dir, name, "date="+p.day, part)
+		tmp, err := t.write(path)
+		if err != nil {
+			return errors.Join(fmt.Errorf("parquet: can't write %s", name), err, removeParts(files, 0))
+		}
+
+		if len(tmp) != 0 {
+			files = append(files, partFile{tmp: tmp, path: path})
+		}
+	}
+
+	for i, f := range files {
+		if err := os.Rename(f.tmp, f.path); err != nil {
+			return errors.Join(err, removeParts(files, i))
+		}
+	}
+
+	state := parquetState{Height: p.last}
+	if err := writeState(filepath.Join(p.dir, parquetStateFile), state); err != nil {
+		return errors.Join(err, removeParts(files, len(files)))
+	}
+	p.state = state
+
+	for _, t := range p.tables {
+		t.reset()
+	}
+	p.reset()
+
+	return nil
+}
+
+type partFile struct {
+	tmp  string
+	path string
+}
+
+// removeParts removes part files renamed before index renamed and temporary files of the others.
+func removeParts(files []partFile, renamed int) error {
+	var err error
+	for i, f := range files {
+		name := f.tmp
+		if i < renamed {
+			name = f.path
+		}
+		err = errors.Join(err, removeIfExists(name))
+	}
+
+	return err
+}
+
+func removeIfExists(name string) error {
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// checkpoint returns the last checkpointed height, buffered or written.
+func (p *Parquet) checkpoint() uint32 {
+	return max(p.last, p.state.Height)
+}
+
+func (p *Parquet) truncate(height uint32) {
+	for _, t := range p.tables {
+		t.truncate(height)
+	}
+	p.blocks = p.blockRows.len()
+	if p.blocks == 0 {
+		p.from = 0
+	}
+}
+
+func (p *Parquet) reset() {
+	p.from = 0
+	p.blocks = 0
+	p.day = ""
+}
@@ -0,0 +1,155 @@
+package sink
+
+import (
+	"context"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/parquet-go/parquet-go"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParquet_PartitionByDay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewParquet(&schema.Sink{Name: "parquet", Type: schema.PARQUET, Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-01-01 23:59:50, 2024-01-01 23:59:59 and 2024-01-02 00:00:09
+	times := []uint32{1704153590, 1704153599, 1704153609}
+	for i, bt := range times {
+		block, txs := testBlock(uint32(i + 1))
+		block.BlockTime = bt
+		if err := s.WriteBlock(ctx, block, txs); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Checkpoint(ctx, block.Height); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cursor, _ := s.Cursor(ctx); cursor != 2 {
+		t.Fatalf("expected first day written up to 2, got %d", cursor)
+	}
+
+	block, txs := testBlock(4)
+	block.BlockTime = times[2]
+	_ = s.WriteBlock(ctx, block, txs)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := parquet.ReadFile[parquetBlock](
+		filepath.Join(dir, "blocks", "date=2024-01-01", "part-0000000001-0000000002.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 2 || blocks[1].BlockTime.Unix() != int64(times[1]) {
+		t.Fatalf("unexpected blocks %v", blocks)
+	}
+
+	// block 4 was never checkpointed, so it's not in the second day part
+	bonds, err := parquet.ReadFile[parquetBond](
+		filepath.Join(dir, "payload_bonds", "date=2024-01-02", "part-0000000003-0000000003.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bonds) != 1 || bonds[0].BlockHeight != 3 {
+		t.Fatalf("unexpected bonds %v", bonds)
+	}
+}
+
+func TestParquet_FlushAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewParquet(&schema.Sink{Name: "parquet", Type: schema.PARQUET, Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, txs := testBlock(1)
+	block.BlockTime = 1704153590
+	if err := s.WriteBlock(ctx, block, txs); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Checkpoint(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// a non empty directory in place of the bonds part makes its rename fail
+	part := "part-0000000001-0000000001.parquet"
+	if err := os.MkdirAll(filepath.Join(dir, "payload_bonds", "date=2024-01-01", part, "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err == nil {
+		t.Fatal("expected flush error")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "blocks", "date=2024-01-01", part)); !os.IsNotExist(err) {
+		t.Fatalf("expected no blocks part after failed flush, got %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "date=2024-01-01", "*.tmp"))
+	if err != nil || len(matches) != 0 {
+		t.Fatalf("expected temporary files removed, got %v, %v", matches, err)
+	}
+
+	if cursor, _ := s.Cursor(ctx); cursor != 0 {
+		t.Fatalf("expected cursor 0, got %d", cursor)
+	}
+}
+
+func TestParquet_WriteBlockAgain(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewParquet(&schema.Sink{Name: "parquet", Type: schema.PARQUET, Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sync fetches again from Cursor, which stays at 0 until the part is written
+	for range 2 {
+		for h := uint32(1); h <= 2; h++ {
+			block, txs := testBlock(h)
+			block.BlockTime = 1704153590
+			if err := s.WriteBlock(ctx, block, txs); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Checkpoint(ctx, h); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	part := "part-0000000001-0000000002.parquet"
+	blocks, err := parquet.ReadFile[parquetBlock](filepath.Join(dir, "blocks", "date=2024-01-01", part))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 2 || blocks[0].Height != 1 || blocks[1].Height != 2 {
+		t.Fatalf("expected blocks 1 and 2 once, got %v", blocks)
+	}
+
+	txs, err := parquet.ReadFile[parquetTransaction](filepath.Join(dir, "transactions", "date=2024-01-01", part))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txs) != 4 {
+		t.Fatalf("expected 4 transactions, got %d", len(txs))
+	}
+}
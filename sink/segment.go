@@ -0,0 +1,372 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	_defaultSegmentSize = 10000
+
+	segmentStateFile = "checkpoint.json"
+)
+
+var segmentTables = []string{schema.BlockTableName, schema.TransactionsTableName}
+
+// Manifest describes a completed segment, it's written once the last height of the segment is checkpointed.
+type Manifest struct {
+	From      uint32         `json:"from"`
+	To        uint32         `json:"to"`
+	Format    string         `json:"format"`
+	Files     []ManifestFile `json:"files"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type ManifestFile struct {
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type segmentState struct {
+	Height uint32                `json:"height"`
+	From   uint32                `json:"from"`
+	Tables map[string]tableState `json:"tables"`
+}
+
+type tableState struct {
+	Offset int64 `json:"offset"`
+	Rows   int64 `json:"rows"`
+}
+
+type segmentTable struct {
+	name string
+	file *os.File
+	w    *bufio.Writer
+	csv  *csv.Writer
+	rows int64
+}
+
+// Segment appends blocks and transactions as NDJSON or CSV to one file per table, files rotate every
+// segment_size heights and each completed segment gets a manifest with row count and checksum.
+type Segment struct {
+	name   string
+	dir    string
+	format schema.SinkType
+	size   uint32
+	from   uint32 // from first height of open segment, zero when nothing is open
+	tables map[string]*segmentTable
+	state  segmentState
+	m      sync.Mutex
+}
+
+func NewSegment(cfg *schema.Sink) (db.Sink, error) {
+	s := &Segment{
+		name:   cfg.Name,
+		dir:    cfg.Path,
+		format: cfg.Type,
+		size:   cfg.SegmentSize,
+		tables: make(map[string]*segmentTable, len(segmentTables)),
+	}
+
+	if s.size == 0 {
+		s.size = _defaultSegmentSize
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := readState(filepath.Join(s.dir, segmentStateFile), &s.state); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Segment) Name() string {
+	return s.name
+}
+
+func (s *Segment) WriteBlock(_ context.Context, block *schema.Block, txs []*schema.Transaction) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if from := s.segmentStart(block.Height); from != s.from {
+		if err := s.closeTables(); err != nil {
+			return err
+		}
+
+		if err := s.open(from); err != nil {
+			return err
+		}
+	}
+
+	if err := s.write(s.tables[schema.BlockTableName], block, blockRecord(block)); err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		if err := s.write(s.tables[schema.TransactionsTableName], tx, transactionRecord(tx)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Segment) Rollback(_ context.Context, height uint32) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if height < s.state.Height {
+		return fmt.Errorf("can't rollback to %d, last checkpoint is %d", height, s.state.Height)
+	}
+
+	if s.from == 0 {
+		return nil
+	}
+
+	for _, t := range s.tables {
+		if err := s.reset(t, s.checkpointOf(t.name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Segment) Checkpoint(_ context.Context, height uint32) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	state := segmentState{Height: height, From: s.state.From, Tables: s.state.Tables}
+
+	if s.from != 0 {
+		state.From = s.from
+		state.Tables = make(map[string]tableState, len(s.tables))
+
+		for _, t := range s.tables {
+			offset, err := s.flush(t)
+			if err != nil {
+				return err
+			}
+			state.Tables[t.name] = tableState{Offset: offset, Rows: t.rows}
+		}
+
+		if height == s.from+s.size-1 {
+			if err := s.writeManifest(); err != nil {
+				return err
+			}
+
+			if err := s.closeTables(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeState(filepath.Join(s.dir, segmentStateFile), state); err != nil {
+		return err
+	}
+	s.state = state
+
+	return nil
+}
+
+func (s *Segment) Cursor(_ context.Context) (uint32, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.state.Height, nil
+}
+
+// Close closes open segment without checkpoint, unflushed rows are dropped on next open.
+func (s *Segment) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.closeTables()
+}
+
+// open opens files of segment starting at from, content after last checkpoint is truncated.
+func (s *Segment) open(from uint32) error {
+	for _, name := range segmentTables {
+		file, err := os.OpenFile(s.filePath(name, from), os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return errors.Join(s.closeTables(), err)
+		}
+
+		t := &segmentTable{name: name, file: file, w: bufio.NewWriter(file)}
+		if s.format == schema.CSV {
+			t.csv = csv.NewWriter(t.w)
+		}
+		s.tables[name] = t
+		s.from = from
+
+		if err := s.reset(t, s.checkpointOf(name)); err != nil {
+			return errors.Join(s.closeTables(), err)
+		}
+	}
+
+	return nil
+}
+
+// checkpointOf returns checkpointed offset and rows of table in open segment.
+func (s *Segment) checkpointOf(table string) tableState {
+	if s.state.From != s.from {
+		return tableState{}
+	}
+
+	return s.state.Tables[table]
+}
+
+// reset drops unflushed rows and truncates file to checkpoint, csv header is written again on empty file.
+func (s *Segment) reset(t *segmentTable, st tableState) error {
+	t.w.Reset(t.file)
+	if err := t.file.Truncate(st.Offset); err != nil {
+		return err
+	}
+
+	if _, err := t.file.Seek(st.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	t.rows = st.Rows
+
+	if t.csv != nil {
+		t.csv = csv.NewWriter(t.w)
+		if st.Offset == 0 {
+			header := blockColumns
+			if t.name == schema.TransactionsTableName {
+				header = transactionColumns
+			}
+
+			return t.csv.Write(header)
+		}
+	}
+
+	return nil
+}
+
+func (s *Segment) write(t *segmentTable, v any, record []string) error {
+	if t.csv != nil {
+		if err := t.csv.Write(record); err != nil {
+			return err
+		}
+	} else {
+		if err := json.NewEncoder(t.w).Encode(v); err != nil {
+			return err
+		}
+	}
+	t.rows++
+
+	return nil
+}
+
+// flush makes table durable and returns current offset.
+func (s *Segment) flush(t *segmentTable) (int64, error) {
+	if t.csv != nil {
+		t.csv.Flush()
+		if err := t.csv.Error(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := t.w.Flush(); err != nil {
+		return 0, err
+	}
+
+	if err := t.file.Sync(); err != nil {
+		return 0, err
+	}
+
+	return t.file.Seek(0, io.SeekCurrent)
+}
+
+func (s *Segment) writeManifest() error {
+	manifest := Manifest{
+		From:      s.from,
+		To:        s.from + s.size - 1,
+		Format:    s.format.String(),
+		Files:     make([]ManifestFile, 0, len(segmentTables)),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	for _, name := range segmentTables {
+		t := s.tables[name]
+		sum, size, err := checksum(t.file.Name())
+		if err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Table:  name,
+			Name:   filepath.Base(t.file.Name()),
+			Rows:   t.rows,
+			Size:   size,
+			SHA256: sum,
+		})
+	}
+
+	return writeState(s.manifestPath(s.from), manifest)
+}
+
+func (s *Segment) closeTables() error {
+	var err error
+	for name, t := range s.tables {
+		if t.csv != nil {
+			t.csv.Flush()
+		}
+		err = errors.Join(err, t.w.Flush(), t.file.Close())
+		delete(s.tables, name)
+	}
+	s.from = 0
+
+	return err
+}
+
+func (s *Segment) segmentStart(height uint32) uint32 {
+	return ((height-1)/s.size)*s.size + 1
+}
+
+func (s *Segment) filePath(table string, from uint32) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s_%s.%s", table, s.rangeName(from), s.format))
+}
+
+func (s *Segment) manifestPath(from uint32) string {
+	return filepath.Join(s.dir, fmt.Sprintf("manifest_%s.json", s.rangeName(from)))
+}
+
+func (s *Segment) rangeName(from uint32) string {
+	return fmt.Sprintf("%010d-%010d", from, from+s.size-1)
+}
+
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
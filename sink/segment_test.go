@@ -0,0 +1,120 @@
+package sink
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeHeights(t *testing.T, s db.Sink, from, to uint32) {
+	t.Helper()
+	for h := from; h <= to; h++ {
+		block, txs := testBlock(h)
+		if err := s.WriteBlock(context.Background(), block, txs); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Checkpoint(context.Background(), h); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSegment_RotateWithManifest(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &schema.Sink{Name: "csv", Type: schema.CSV, Path: dir, SegmentSize: 2}
+
+	s, err := NewSegment(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeHeights(t, s, 1, 3)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "manifest_0000000001-0000000002.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.From != 1 || manifest.To != 2 || len(manifest.Files) != 2 {
+		t.Fatalf("unexpected manifest %s", b)
+	}
+
+	if manifest.Files[0].Rows != 2 || manifest.Files[1].Rows != 4 {
+		t.Fatalf("expected 2 blocks and 4 transactions, got %d and %d", manifest.Files[0].Rows, manifest.Files[1].Rows)
+	}
+
+	for _, f := range manifest.Files {
+		sum, _, err := checksum(filepath.Join(dir, f.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum != f.SHA256 {
+			t.Fatalf("checksum mismatch for %s", f.Name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "manifest_0000000003-0000000004.json")); !os.IsNotExist(err) {
+		t.Fatal("open segment must not have manifest")
+	}
+}
+
+func TestSegment_ResumeAfterRollback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &schema.Sink{Name: "csv", Type: schema.CSV, Path: dir, SegmentSize: 10}
+
+	s, err := NewSegment(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeHeights(t, s, 1, 2)
+	block, txs := testBlock(3)
+	_ = s.WriteBlock(ctx, block, txs)
+	if err := s.Rollback(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.WriteBlock(ctx, block, txs)
+	_ = s.Close()
+
+	// block 3 was never checkpointed, reopening must drop it
+	s, err = NewSegment(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursor, _ := s.Cursor(ctx); cursor != 2 {
+		t.Fatalf("expected cursor 2, got %d", cursor)
+	}
+
+	writeHeights(t, s, 3, 3)
+	_ = s.Close()
+
+	f, err := os.Open(filepath.Join(dir, "blocks_0000000001-0000000010.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 4 || rows[0][0] != "height" || rows[3][0] != "3" {
+		t.Fatalf("expected header and 3 blocks, got %v", rows)
+	}
+}
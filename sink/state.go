@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// readState decodes json state file into v, missing file leaves v untouched.
+func readState(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// writeState replaces json state file atomically.
+func writeState(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,280 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/Pactus-Contrib/Indexer/version"
+	"io"
+	"time"
+)
+
+const (
+	// Format names the archive, it's the first field of every snapshot.
+	Format = "pactus-indexer-snapshot"
+	// Version of archive layout, restore rejects newer versions.
+	Version = 1
+
+	_batchHeights = 1000
+	_batchRows    = 1000
+)
+
+// Manifest is the first line of a snapshot.
+type Manifest struct {
+	Format      string    `json:"format"`
+	Version     int       `json:"version"`
+	Height      uint32    `json:"height"`
+	IndexerUuid string    `json:"indexer_uuid"`
+	Source      string    `json:"source"` // Source engine of the dumped database
+	AppVersion  string    `json:"app_version"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Summary is number of rows in a snapshot, it's the last line so a truncated archive is detected.
+type Summary struct {
+	Blocks       int64 `json:"blocks"`
+	Transactions int64 `json:"transactions"`
+}
+
+// record is a line of the archive after manifest, either a row of table or the end summary.
+type record struct {
+	Table string          `json:"table,omitempty"`
+	Row   json.RawMessage `json:"row,omitempty"`
+	End   *Summary        `json:"end,omitempty"`
+}
+
+// Create writes blocks and transactions of database up to height inclusive to w as gzip compressed json lines.
+func Create(ctx context.Context, database db.Database, w io.Writer, indexerId string, height uint32) (*Summary,
+	error) {
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+
+	if err := enc.Encode(&Manifest{
+		Format:      Format,
+		Version:     Version,
+		Height:      height,
+		IndexerUuid: indexerId,
+		Source:      database.Engine(),
+		AppVersion:  version.Semantic(),
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	for from := uint32(1); from <= height; from += _batchHeights {
+		to := min(from+_batchHeights-1, height)
+
+		blocks := make([]*schema.Block, 0)
+		if err := database.FindRange(ctx, schema.BlockTableName, "height", from, to, &blocks); err != nil {
+			return nil, err
+		}
+
+		txs := make([]*schema.Transaction, 0)
+		if err := database.FindRange(ctx, schema.TransactionsTableName, "block_height", from, to,
+			&txs); err != nil {
+			return nil, err
+		}
+
+		for _, b := range blocks {
+			if err := encodeRow(enc, schema.BlockTableName, b); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, tx := range txs {
+			if err := encodeRow(enc, schema.TransactionsTableName, tx); err != nil {
+				return nil, err
+			}
+		}
+
+		summary.Blocks += int64(len(blocks))
+		summary.Transactions += int64(len(txs))
+
+		if to == height {
+			break
+		}
+	}
+
+	if err := enc.Encode(&record{End: summary}); err != nil {
+		return nil, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return summary, zw.Close()
+}
+
+func encodeRow(enc *json.Encoder, table string, row any) error {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	return enc.Encode(&record{Table: table, Row: raw})
+}
+
+func decodeManifest(dec *json.Decoder) (*Manifest, error) {
+	m := new(Manifest)
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("snapshot manifest is invalid: %w", err)
+	}
+
+	if m.Format != Format {
+		return nil, errors.New("file is not an indexer snapshot")
+	}
+
+	if m.Version > Version {
+		return nil, fmt.Errorf("snapshot version %d is newer than supported version %d", m.Version, Version)
+	}
+
+	return m, nil
+}
+
+// Check reads every row of snapshot r and compares their number with the end summary, it's run before Restore
+// so a truncated archive doesn't leave a database half restored.
+func Check(r io.Reader) (*Manifest, *Summary, error) {
+	return scan(r, func(*Manifest) error { return nil }, func(string, json.RawMessage) error { return nil })
+}
+
+// Restore loads snapshot r into every database of pool and moves cursor of indexer past height of the snapshot
+// once all rows are stored. Rows are upserted, so a failed restore is resumed by running it again: rows stored
+// by the failed run are replaced with the same rows and the cursor only moves at the end. Databases whose cursor
+// is past height of the snapshot are refused, restoring them would move their cursor back.
+func Restore(ctx context.Context, r io.Reader, pool *db.Pool, indexerId string) (*Manifest, *Summary, error) {
+	cursors, err := pool.GetCursor(ctx, indexerId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks := make([]*schema.Block, 0, _batchRows)
+	txs := make([]*schema.Transaction, 0, _batchRows)
+	flush := func() error {
+		if err := pool.SaveBlocks(ctx, blocks...); err != nil {
+			return err
+		}
+		blocks = blocks[:0]
+
+		if err := pool.SaveTransactions(ctx, txs...); err != nil {
+			return err
+		}
+		txs = txs[:0]
+
+		return nil
+	}
+
+	begin := func(m *Manifest) error {
+		for name, next := range cursors {
+			if next > m.Height+1 {
+				return fmt.Errorf("database %s is indexed up to height %d, snapshot at height %d can't be "+
+					"restored into it", name, next-1, m.Height)
+			}
+		}
+
+		return nil
+	}
+
+	m, summary, err := scan(r, begin, func(table string, row json.RawMessage) error {
+		switch table {
+		case schema.BlockTableName:
+			b := new(schema.Block)
+			if err := json.Unmarshal(row, b); err != nil {
+				return err
+			}
+			blocks = append(blocks, b)
+		case schema.TransactionsTableName:
+			tx := new(schema.Transaction)
+			if err := json.Unmarshal(row, tx); err != nil {
+				return err
+			}
+			txs = append(txs, tx)
+		}
+
+		if len(blocks)+len(txs) >= _batchRows {
+			return flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := flush(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := pool.UpdateCursor(ctx, indexerId, m.Height); err != nil {
+		return nil, nil, err
+	}
+
+	return m, summary, nil
+}
+
+// scan decodes manifest and passes it to begin, then passes every row to fn until the end summary, which must
+// match the rows read.
+func scan(r io.Reader, begin func(m *Manifest) error, fn func(table string, row json.RawMessage) error) (*Manifest,
+	*Summary, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	m, err := decodeManifest(dec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := begin(m); err != nil {
+		return nil, nil, err
+	}
+
+	read := &Summary{}
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, nil, errors.New("snapshot is truncated, end summary is missing")
+			}
+
+			return nil, nil, err
+		}
+
+		if rec.End != nil {
+			if *rec.End != *read {
+				return nil, nil, fmt.Errorf("snapshot has %d blocks and %d transactions, but %d and %d are read",
+					rec.End.Blocks, rec.End.Transactions, read.Blocks, read.Transactions)
+			}
+
+			// reading to the end verifies gzip checksum
+			if _, err := io.Copy(io.Discard, zr); err != nil {
+				return nil, nil, fmt.Errorf("snapshot is corrupted: %w", err)
+			}
+
+			return m, read, nil
+		}
+
+		switch rec.Table {
+		case schema.BlockTableName:
+			read.Blocks++
+		case schema.TransactionsTableName:
+			read.Transactions++
+		default:
+			return nil, nil, fmt.Errorf("snapshot has rows of unknown table %s", rec.Table)
+		}
+
+		if err := fn(rec.Table, rec.Row); err != nil {
+			return nil, nil, err
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"github.com/Pactus-Contrib/Indexer/db/dbtest"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testIndexerId = "a5b8f3c2-0000-4000-8000-000000000000"
+
+func TestCreateRestore(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	source := dbtest.Pool(t, testIndexerId, dbtest.Memory("source"))
+	for height := uint32(1); height <= 3; height++ {
+		h := strconv.Itoa(int(height))
+		if err := source.ReplaceBlock(ctx, &schema.Block{Height: height, Hash: "block-" + h, TotalTransactions: 1,
+			Committers: []int32{1, 2}}, []*schema.Transaction{{Hash: "tx-" + h, BlockHeight: height, Value: 5,
+			CreatedAt: createdAt}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	database, _ := source.Engine("source")
+	buf := new(bytes.Buffer)
+	summary, err := Create(ctx, database, buf, testIndexerId, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Blocks != 2 || summary.Transactions != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	if _, _, err := Check(bytes.NewReader(buf.Bytes()[:buf.Len()-10])); err == nil {
+		t.Fatal("expected error for truncated snapshot")
+	}
+
+	m, _, err := Check(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != Version || m.Height != 2 || m.Source != schema.MEMORY.String() {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	target := dbtest.Pool(t, testIndexerId, dbtest.Memory("target"))
+	if _, _, err := Restore(ctx, bytes.NewReader(buf.Bytes()), target, testIndexerId); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := target.Engine("target")
+	blocks := make([]*schema.Block, 0)
+	if err := restored.FindRange(ctx, schema.BlockTableName, "height", 1, 3, &blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 2 || blocks[1].Hash != "block-2" || len(blocks[1].Committers) != 2 {
+		t.Fatalf("unexpected blocks %+v", blocks)
+	}
+
+	txs := make([]*schema.Transaction, 0)
+	if err := restored.FindRange(ctx, schema.TransactionsTableName, "block_height", 1, 3, &txs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txs) != 2 || txs[0].Value != 5 || !txs[0].CreatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected transactions %+v", txs)
+	}
+
+	cursors, err := target.GetCursor(ctx, testIndexerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursors["target"] != 3 {
+		t.Fatalf("expected cursor 3, got %v", cursors)
+	}
+
+	if err := target.UpdateCursor(ctx, testIndexerId, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Restore(ctx, bytes.NewReader(buf.Bytes()), target, testIndexerId); err == nil {
+		t.Fatal("expected error for restore into database past snapshot height")
+	}
+}
+
+func TestRestoreResumes(t *testing.T) {
+	ctx := context.Background()
+
+	source := dbtest.Pool(t, testIndexerId, dbtest.Memory("source"))
+	for height := uint32(1); height <= 3; height++ {
+		h := strconv.Itoa(int(height))
+		if err := source.ReplaceBlock(ctx, &schema.Block{Height: height, Hash: "block-" + h},
+			[]*schema.Transaction{{Hash: "tx-" + h, BlockHeight: height}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	database, _ := source.Engine("source")
+	buf := new(bytes.Buffer)
+	if _, err := Create(ctx, database, buf, testIndexerId, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows of a restore which failed before the cursor moved
+	target := dbtest.Pool(t, testIndexerId, dbtest.Memory("target"))
+	if err := target.SaveBlocks(ctx, &schema.Block{Height: 1, Hash: "block-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.SaveTransactions(ctx, &schema.Transaction{Hash: "tx-1", BlockHeight: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if _, _, err := Restore(ctx, bytes.NewReader(buf.Bytes()), target, testIndexerId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, table := range []string{schema.BlockTableName, schema.TransactionsTableName} {
+		counts, err := target.Count(ctx, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if counts["target"] != 3 {
+			t.Fatalf("expected 3 rows in %s, got %d", table, counts["target"])
+		}
+	}
+
+	cursors, err := target.GetCursor(ctx, testIndexerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursors["target"] != 4 {
+		t.Fatalf("expected cursor 4, got %v", cursors)
+	}
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup_FileExporter(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	flush, err := Setup(ctx, &schema.Tracing{Exporter: schema.FileExporter, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Tracer().Start(ctx, "write sqlite")
+	End(span, errors.New("database is locked"))
+
+	if err := flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `"Name":"write sqlite"`) || !strings.Contains(string(b), "database is locked") {
+		t.Fatalf("span not exported: %s", b)
+	}
+}
@@ -0,0 +1,23 @@
+package webhook
+
+import (
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"slices"
+)
+
+// Match reports whether transaction passes the webhook filter, nil filter matches everything.
+func Match(f *schema.WebhookFilter, tx *schema.Transaction) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Types) != 0 && !slices.Contains(f.Types, tx.Type) {
+		return false
+	}
+
+	if len(f.Addresses) != 0 && !slices.Contains(f.Addresses, tx.From) && !slices.Contains(f.Addresses, tx.To) {
+		return false
+	}
+
+	return tx.Value >= f.MinValue
+}
@@ -0,0 +1,333 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Pactus-Contrib/Indexer/db"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Indexer-Signature"
+	EventHeader     = "X-Indexer-Event"
+	DeliveryHeader  = "X-Indexer-Delivery"
+
+	_defaultMaxRetries   = 8
+	_defaultTimeout      = 10 * time.Second
+	_defaultPollInterval = 2 * time.Second
+	_defaultPageSize     = 100
+	_baseBackoff         = 2 * time.Second
+	_maxBackoff          = 10 * time.Minute
+)
+
+// Payload is the json body posted to webhook targets.
+type Payload struct {
+	Id        string              `json:"id"`
+	Event     schema.WebhookEvent `json:"event"`
+	CreatedAt time.Time           `json:"created_at"`
+	Data      any                 `json:"data"`
+}
+
+type BlockData struct {
+	Block        *schema.Block         `json:"block"`
+	Transactions []*schema.Transaction `json:"transactions"`
+}
+
+// Dispatcher writes webhook events to the outbox and delivers pending rows in background.
+type Dispatcher struct {
+	outbox       db.Executor
+	mu           sync.RWMutex
+	targets      map[string]*schema.Webhook
+	client       *http.Client
+	logger       logging.Logger
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+func NewDispatcher(outbox db.Executor, targets []*schema.Webhook, logger logging.Logger) *Dispatcher {
+	d := &Dispatcher{
+		outbox:       outbox,
+		client:       &http.Client{},
+		logger:       logger,
+		pollInterval: _defaultPollInterval,
+		now:          time.Now,
+	}
+
+	d.SetTargets(targets)
+
+	return d
+}
+
+// SetTargets replaces webhook targets, pending rows of removed targets stay in outbox until they are added back.
+func (d *Dispatcher) SetTargets(targets []*schema.Webhook) {
+	m := make(map[string]*schema.Webhook, len(targets))
+	for _, t := range targets {
+		m[t.Name] = t
+	}
+
+	d.mu.Lock()
+	d.targets = m
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) target(name string) (*schema.Webhook, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	t, ok := d.targets[name]
+
+	return t, ok
+}
+
+// OutboxRows returns outbox rows for every target interested in the block, pool stores them with the block.
+func (d *Dispatcher) OutboxRows(block *schema.Block, txs []*schema.Transaction) ([]any, error) {
+	rows := make([]any, 0)
+	now := d.now()
+
+	d.mu.RLock()
+	targets := d.targets
+	d.mu.RUnlock()
+
+	for _, t := range targets {
+		switch t.Event {
+		case schema.BlockEvent:
+			row, err := d.newRow(t, block.Height, block.Hash, now, BlockData{Block: block, Transactions: txs})
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		case schema.TransactionEvent:
+			for _, tx := range txs {
+				if !Match(t.Filter, tx) {
+					continue
+				}
+
+				row, err := d.newRow(t, block.Height, tx.Hash, now, tx)
+				if err != nil {
+					return nil, err
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// Run delivers pending outbox rows until context is canceled.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.deliverPending(ctx); err != nil && ctx.Err() == nil {
+			d.logger.ErrorContext(ctx, true, "webhook delivery failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverPending reads pending rows page by page and delivers every page concurrently per target, rows of a
+// target are posted one by one so a slow or failing target doesn't delay the others.
+func (d *Dispatcher) deliverPending(ctx context.Context) error {
+	now := d.now()
+	after := ""
+
+	for {
+		rows := make([]*schema.WebhookOutbox, 0, _defaultPageSize)
+		if err := d.outbox.FindPage(ctx, schema.WebhookOutboxTableName, "status", schema.OutboxPending,
+			"event_id", after, _defaultPageSize, &rows); err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+		after = rows[len(rows)-1].EventId
+
+		byTarget := make(map[string][]*schema.WebhookOutbox)
+		for _, row := range rows {
+			if row.NextAttemptAt.After(now) {
+				continue
+			}
+			byTarget[row.Webhook] = append(byTarget[row.Webhook], row)
+		}
+
+		gp, gpCtx := errgroup.WithContext(ctx)
+		for name, pending := range byTarget {
+			t, ok := d.target(name)
+			if !ok {
+				d.logger.WarnContext(ctx, false, "webhook target not configured, skip delivery",
+					"webhook", name, "rows", len(pending))
+				continue
+			}
+
+			gp.Go(func() error {
+				for _, row := range pending {
+					if err := d.attempt(gpCtx, t, row); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+		}
+
+		if err := gp.Wait(); err != nil {
+			return err
+		}
+
+		if len(rows) < _defaultPageSize {
+			return nil
+		}
+	}
+}
+
+// attempt posts a single row and stores the outcome in the outbox.
+func (d *Dispatcher) attempt(ctx context.Context, t *schema.Webhook, row *schema.WebhookOutbox) error {
+	sendErr := d.send(ctx, t, row)
+	row.Attempts++
+
+	fields := map[string]any{"attempts": row.Attempts}
+	switch {
+	case sendErr == nil:
+		fields["status"] = schema.OutboxDelivered
+		fields["last_error"] = ""
+		d.logger.DebugContext(ctx, false, "webhook delivered", "webhook", t.Name, "event_id", row.EventId)
+	case row.Attempts > maxRetries(t):
+		fields["status"] = schema.OutboxFailed
+		fields["last_error"] = sendErr.Error()
+		d.logger.ErrorContext(ctx, true, "webhook delivery gave up", "webhook", t.Name,
+			"event_id", row.EventId, "attempts", row.Attempts, "err", sendErr)
+	default:
+		fields["next_attempt_at"] = d.now().Add(Backoff(row.Attempts))
+		fields["last_error"] = sendErr.Error()
+		d.logger.WarnContext(ctx, false, "webhook delivery failed, retry later", "webhook", t.Name,
+			"event_id", row.EventId, "attempts", row.Attempts, "err", sendErr)
+	}
+
+	return d.outbox.UpdateFields(ctx, schema.WebhookOutboxTableName, "event_id", row.EventId, fields)
+}
+
+func (d *Dispatcher) send(ctx context.Context, t *schema.Webhook, row *schema.WebhookOutbox) error {
+	timeout := _defaultTimeout
+	if t.Timeout != 0 {
+		timeout = time.Duration(t.Timeout) * time.Second
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body := []byte(row.Payload)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, row.Event)
+	req.Header.Set(DeliveryHeader, row.EventId)
+	if len(t.Secret) != 0 {
+		req.Header.Set(SignatureHeader, Sign(t.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("unexpected status code " + strconv.Itoa(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// newRow builds outbox row of data, event id is derived from target and hash of block or transaction so
+// writing a block again keeps its stored rows instead of adding new ones.
+func (d *Dispatcher) newRow(t *schema.Webhook, height uint32, hash string, now time.Time,
+	data any) (*schema.WebhookOutbox, error) {
+	id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(t.Name+"/"+t.Event.String()+"/"+hash)).String()
+	body, err := json.Marshal(Payload{
+		Id:        id,
+		Event:     t.Event,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.WebhookOutbox{
+		EventId:       id,
+		Webhook:       t.Name,
+		Event:         t.Event.String(),
+		BlockHeight:   height,
+		Payload:       string(body),
+		Status:        schema.OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// Sign returns hex encoded HMAC-SHA256 of body, prefixed with algorithm name.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify checks signature header created by Sign, receivers can use it to authenticate payloads.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Backoff returns exponential delay for given attempt, capped at 10 minutes.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := _baseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= _maxBackoff {
+			return _maxBackoff
+		}
+	}
+
+	return delay
+}
+
+// maxRetries returns retries of target after the first attempt, zero uses the default and -1 disables retries.
+func maxRetries(t *schema.Webhook) int {
+	switch t.MaxRetries {
+	case 0:
+		return _defaultMaxRetries
+	case -1:
+		return 0
+	}
+
+	return t.MaxRetries
+}
@@ -0,0 +1,359 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Pactus-Contrib/Indexer/logging"
+	"github.com/Pactus-Contrib/Indexer/schema"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// outboxStore keeps outbox rows in memory for tests.
+type outboxStore struct {
+	m    sync.Mutex
+	rows map[string]*schema.WebhookOutbox
+}
+
+func (o *outboxStore) FindOne(_ context.Context, _ string, _ string, _ any, _ any) error {
+	return nil
+}
+
+func (o *outboxStore) FindMany(_ context.Context, _ string, _ string, val any, resultSlicePtr any) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	res := resultSlicePtr.(*[]*schema.WebhookOutbox)
+	for _, r := range o.rows {
+		if r.Status == val {
+			c := *r
+			*res = append(*res, &c)
+		}
+	}
+
+	return nil
+}
+
+func (o *outboxStore) FindRange(_ context.Context, _ string, _ string, _, _ any, _ any) error {
+	return nil
+}
+
+func (o *outboxStore) FindPage(_ context.Context, _ string, _ string, val any, _ string, after any, limit int,
+	resultSlicePtr any) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	res := resultSlicePtr.(*[]*schema.WebhookOutbox)
+	for _, r := range o.rows {
+		if r.Status == val && r.EventId > after.(string) {
+			c := *r
+			*res = append(*res, &c)
+		}
+	}
+
+	slices.SortFunc(*res, func(a, b *schema.WebhookOutbox) int { return strings.Compare(a.EventId, b.EventId) })
+	*res = (*res)[:min(limit, len(*res))]
+
+	return nil
+}
+
+func (o *outboxStore) InsertOne(_ context.Context, _ string, dataPtr any) error {
+	return o.InsertMany(context.Background(), "", []any{dataPtr})
+}
+
+func (o *outboxStore) InsertMany(_ context.Context, _ string, dataPtr []any) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	for _, d := range dataPtr {
+		r := d.(*schema.WebhookOutbox)
+		o.rows[r.EventId] = r
+	}
+
+	return nil
+}
+
+func (o *outboxStore) UpdateOne(ctx context.Context, t string, key string, val any, updKey string, updVal any) error {
+	return o.UpdateFields(ctx, t, key, val, map[string]any{updKey: updVal})
+}
+
+func (o *outboxStore) UpdateFields(_ context.Context, _ string, _ string, val any, fields map[string]any) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	r := o.rows[val.(string)]
+	for k, v := range fields {
+		switch k {
+		case "status":
+			r.Status = v.(string)
+		case "attempts":
+			r.Attempts = v.(int)
+		case "next_attempt_at":
+			r.NextAttemptAt = v.(time.Time)
+		case "last_error":
+			r.LastError = v.(string)
+		}
+	}
+
+	return nil
+}
+
+func setup(t *testing.T, targets ...*schema.Webhook) (*Dispatcher, *outboxStore) {
+	t.Helper()
+	logger, err := logging.New(logging.ConsoleHandler, logging.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &outboxStore{rows: make(map[string]*schema.WebhookOutbox)}
+
+	return NewDispatcher(store, targets, logger), store
+}
+
+// enqueue stores outbox rows of block like pool does while writing it.
+func enqueue(t *testing.T, d *Dispatcher, store *outboxStore, block *schema.Block, txs []*schema.Transaction) {
+	t.Helper()
+	rows, err := d.OutboxRows(block, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InsertMany(context.Background(), schema.WebhookOutboxTableName, rows); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testBlock() (*schema.Block, []*schema.Transaction) {
+	return &schema.Block{Height: 10, Hash: "aa"}, []*schema.Transaction{
+		{Hash: "t1", BlockHeight: 10, Type: "transfer", From: "pc1a", To: "pc1b", Value: 100},
+		{Hash: "t2", BlockHeight: 10, Type: "bond", From: "pc1c", To: "pc1d", Value: 5},
+	}
+}
+
+func TestDispatcher_DeliverSigned(t *testing.T) {
+	var got []byte
+	var signature, event string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		event = r.Header.Get(EventHeader)
+	}))
+	defer srv.Close()
+
+	d, store := setup(t, &schema.Webhook{Name: "blocks", URL: srv.URL, Secret: "s3cret", Event: schema.BlockEvent})
+	block, txs := testBlock()
+
+	enqueue(t, d, store, block, txs)
+
+	if err := d.deliverPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Verify("s3cret", got, signature) {
+		t.Fatalf("invalid signature %s", signature)
+	}
+
+	if event != schema.BlockEvent.String() {
+		t.Fatalf("expected event %s, got %s", schema.BlockEvent, event)
+	}
+
+	var payload struct {
+		Data BlockData `json:"data"`
+	}
+	if err := json.Unmarshal(got, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Data.Block.Height != 10 || len(payload.Data.Transactions) != 2 {
+		t.Fatalf("unexpected payload %s", got)
+	}
+
+	for _, r := range store.rows {
+		if r.Status != schema.OutboxDelivered || r.Attempts != 1 {
+			t.Fatalf("expected delivered row after 1 attempt, got %s after %d", r.Status, r.Attempts)
+		}
+	}
+}
+
+func TestDispatcher_RetryWithBackoff(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	d, store := setup(t, &schema.Webhook{Name: "txs", URL: srv.URL, Event: schema.TransactionEvent,
+		Filter: &schema.WebhookFilter{Types: []string{"transfer"}}})
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	block, txs := testBlock()
+
+	enqueue(t, d, store, block, txs)
+
+	if len(store.rows) != 1 {
+		t.Fatalf("expected 1 filtered row, got %d", len(store.rows))
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := d.deliverPending(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// a second pass before backoff elapses must not call the target
+		if err := d.deliverPending(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if calls != i {
+			t.Fatalf("expected %d calls, got %d", i, calls)
+		}
+
+		now = now.Add(Backoff(i))
+	}
+
+	for _, r := range store.rows {
+		if r.Status != schema.OutboxDelivered || r.Attempts != 3 {
+			t.Fatalf("expected delivered row after 3 attempts, got %s after %d", r.Status, r.Attempts)
+		}
+	}
+}
+
+func TestDispatcher_GiveUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		maxRetries int
+		attempts   int
+	}{
+		{1, 2},
+		{-1, 1}, // retries disabled
+	}
+
+	for _, c := range cases {
+		d, store := setup(t, &schema.Webhook{Name: "blocks", URL: srv.URL, Event: schema.BlockEvent,
+			MaxRetries: c.maxRetries})
+		now := time.Now()
+		d.now = func() time.Time { return now }
+		block, txs := testBlock()
+
+		enqueue(t, d, store, block, txs)
+
+		for i := 0; i < 3; i++ {
+			if err := d.deliverPending(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(_maxBackoff)
+		}
+
+		for _, r := range store.rows {
+			if r.Status != schema.OutboxFailed || r.Attempts != c.attempts {
+				t.Fatalf("max_retries %d: expected failed row after %d attempts, got %s after %d", c.maxRetries,
+					c.attempts, r.Status, r.Attempts)
+			}
+		}
+	}
+}
+
+func TestDispatcher_DeliverPages(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	d, store := setup(t, &schema.Webhook{Name: "blocks", URL: srv.URL, Event: schema.BlockEvent},
+		&schema.Webhook{Name: "txs", URL: srv.URL, Event: schema.TransactionEvent})
+
+	// more rows than a page, of two targets
+	for h := range 2 * _defaultPageSize {
+		block := &schema.Block{Height: uint32(h), Hash: "b" + strconv.Itoa(h)}
+		enqueue(t, d, store, block, []*schema.Transaction{{Hash: "t" + strconv.Itoa(h), BlockHeight: uint32(h)}})
+	}
+
+	if err := d.deliverPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if int(calls.Load()) != len(store.rows) {
+		t.Fatalf("expected %d deliveries, got %d", len(store.rows), calls.Load())
+	}
+
+	for _, r := range store.rows {
+		if r.Status != schema.OutboxDelivered {
+			t.Fatalf("expected every row delivered, %s is %s", r.EventId, r.Status)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tx := &schema.Transaction{Type: "transfer", From: "pc1a", To: "pc1b", Value: 100}
+
+	cases := []struct {
+		filter *schema.WebhookFilter
+		want   bool
+	}{
+		{nil, true},
+		{&schema.WebhookFilter{Types: []string{"bond"}}, false},
+		{&schema.WebhookFilter{Addresses: []string{"pc1b"}}, true},
+		{&schema.WebhookFilter{Addresses: []string{"pc1z"}}, false},
+		{&schema.WebhookFilter{MinValue: 101}, false},
+	}
+
+	for i, c := range cases {
+		if got := Match(c.filter, tx); got != c.want {
+			t.Fatalf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != _baseBackoff || Backoff(2) != 2*_baseBackoff || Backoff(100) != _maxBackoff {
+		t.Fatal("unexpected backoff")
+	}
+}
+
+func TestDispatcher_OutboxRowsStableIds(t *testing.T) {
+	d, _ := setup(t, &schema.Webhook{Name: "blocks", Event: schema.BlockEvent},
+		&schema.Webhook{Name: "txs", Event: schema.TransactionEvent})
+	block, txs := testBlock()
+
+	first, err := d.OutboxRows(block, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writing a block again after a failure must replace its rows, not enqueue them twice
+	second, err := d.OutboxRows(block, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("expected 3 rows, got %d and %d", len(first), len(second))
+	}
+
+	ids := make(map[string]bool)
+	for _, r := range first {
+		ids[r.(*schema.WebhookOutbox).EventId] = true
+	}
+
+	for _, r := range second {
+		if !ids[r.(*schema.WebhookOutbox).EventId] {
+			t.Fatalf("event id %s changed between writes", r.(*schema.WebhookOutbox).EventId)
+		}
+	}
+}